@@ -0,0 +1,516 @@
+// command implementations
+//
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plord12/360tools/metadata"
+	"github.com/plord12/360tools/places"
+	"github.com/plord12/360tools/quota"
+	"github.com/plord12/360tools/report"
+	"github.com/plord12/360tools/streetview"
+	"github.com/plord12/360tools/track"
+	"github.com/plord12/360tools/umap"
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+func runUpload(fs *flag.FlagSet, args []string) int {
+	var (
+		google          = addGoogleFlags(fs)
+		skipConnections = fs.Bool("skip-connections", false, "skip Google Maps connections")
+		placeId         = fs.String("placeid", "", "place id (from pois command output) to add to upload")
+		placesFile      = fs.String("places", "", "CSV file of photo file and place id, giving a place per photo.  Files are relative to the CSV file.")
+		autoPlace       = fs.Bool("auto-place", false, "Give each photo without a place the nearest place found by Places nearby search (needs an API key).  Not searched with --dry-run.")
+		placeRadius     = fs.Float64("place-radius", 50, "Furthest, in metres, a place is found from a photo with --auto-place.")
+		placeType       = fs.String("place-type", "point_of_interest", "Type of place found with --auto-place, such as store or cafe, empty for any.")
+		journal         = fs.String("journal", "upload-journal.json", "Journal file recording upload progress.  Re-running with the same journal resumes an interrupted upload.")
+		dryRun          = fs.Bool("dry-run", false, "print what would be uploaded without calling Google")
+		planFormat      = fs.String("plan-format", "text", "Format of the --dry-run plan - text or json.")
+		workers         = fs.Int("workers", 4, "Number of photos to upload in parallel.")
+		order           = fs.String("order", streetview.OrderArguments, "Order to connect photos in - "+strings.Join(streetview.OrderModes, ", ")+".")
+		connect         = fs.String("connect", streetview.ConnectChain, "How to connect photos - "+strings.Join(streetview.ConnectStrategies, ", ")+".  Ignored if the manifest gives connections.")
+		neighbours      = fs.Int("neighbours", streetview.DefaultNeighbours, "Most photos each photo is connected to, with --connect nearest.")
+		maxDistance     = fs.Float64("max-distance", streetview.DefaultMaxDistance, "Furthest, in metres, photos are connected with --connect nearest or gabriel, or junctions are linked with --connect junctions.")
+		joinRadius      = fs.Float64("join-radius", 0, "Join the ends and junctions of the new photos to photos already published within this many metres, 0 to not.")
+		publishTimeout  = fs.Duration("publish-timeout", streetview.DefaultPublishTimeout, "How long to wait for Google to publish the photos before connecting them.")
+		strict          = addStrictFlag(fs)
+		reportFlags     = addReportFlags(fs)
+		manifestFile    = addManifestFlag(fs)
+		keys            = addAPIKeyFlags(fs, google.rate)
+	)
+	in, code := parseInputs(fs, args, manifestFile)
+	if code != exitOK {
+		return code
+	}
+	if *planFormat != "text" && *planFormat != "json" {
+		fmt.Fprintf(fs.Output(), "Invalid plan format - must be one of text or json\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+	if !google.valid(fs) {
+		return exitUsage
+	}
+	if !validChoice(*order, streetview.OrderModes) {
+		fmt.Fprintf(fs.Output(), "Invalid order - must be one of %s\n\n", strings.Join(streetview.OrderModes, ", "))
+		fs.Usage()
+		return exitUsage
+	}
+	if !validChoice(*connect, streetview.ConnectStrategies) {
+		fmt.Fprintf(fs.Output(), "Invalid connection strategy - must be one of %s\n\n", strings.Join(streetview.ConnectStrategies, ", "))
+		fs.Usage()
+		return exitUsage
+	}
+	if !reportFlags.valid(fs) {
+		return exitUsage
+	}
+
+	options := streetview.Options{SkipConnections: *skipConnections, PlaceId: *placeId, Journal: *journal, Workers: *workers, Report: report.New(fs.Name()), Tour: in.tour, Strict: *strict, PublishTimeout: *publishTimeout,
+		Order: *order, Connect: *connect, Neighbours: *neighbours, MaxDistance: *maxDistance, JoinRadius: *joinRadius}
+	if *placesFile != "" {
+		var err error
+		options.PlaceIds, err = places.LoadAssignments(*placesFile)
+		if err != nil {
+			log.Println(err)
+			return exitError
+		}
+	}
+	if *autoPlace && *dryRun {
+		log.Println("Not searching for places with --auto-place in a dry run")
+	}
+	if *autoPlace && !*dryRun {
+		apiKey, err := valueOrFile(*keys.apikey, *keys.apiKeyFile)
+		if err != nil {
+			log.Printf("Unable to read API key: %v", err)
+			return exitError
+		}
+		finder := &places.Finder{Client: quota.Wrap(nil, *keys.rate), APIKey: apiKey, Radius: *placeRadius, Type: *placeType}
+		options.FindPlace = func(file string, latitude float64, longitude float64) (string, error) {
+			place, distance, err := finder.Nearest(latitude, longitude)
+			if err != nil {
+				return "", err
+			}
+			if place == nil {
+				log.Printf("%s: no place within %.0fm", file, finder.Radius)
+				return "", nil
+			}
+			log.Printf("%s: place %s (%s) %.0fm away", file, place.Name, place.PlaceId, distance)
+			return place.PlaceId, nil
+		}
+	}
+
+	// work out what to do before talking to google
+	//
+	if *dryRun {
+		plan, err := streetview.Plan(options, in.files)
+		if err != nil {
+			log.Println(err)
+			return exitError
+		}
+		for _, skipped := range in.skipped {
+			if skipped.Failed {
+				plan.Photos = append(plan.Photos, &streetview.PlannedPhoto{File: skipped.File, Skipped: skipped.Reason})
+			}
+		}
+		err = streetview.PrintPlan(os.Stdout, plan, *planFormat)
+		if err != nil {
+			log.Println(err)
+			return exitError
+		}
+		uploads := 0
+		for _, photo := range plan.Photos {
+			if photo.Skipped == "" {
+				uploads++
+			}
+		}
+		return exitCode(uploads, len(plan.Photos)-uploads)
+	}
+
+	client, err := startOauth(google)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	in.reportSkipped(options.Report)
+
+	// stop cleanly on ctrl-c, so the journal and report are still written
+	//
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = client.Upload(ctx, options, in.files)
+	return reportFlags.finish(options.Report, err)
+}
+
+func runPois(fs *flag.FlagSet, args []string) int {
+	var (
+		keys         = addAPIKeyFlags(fs, nil)
+		radius       = fs.Float64("radius", 0, "Search this many metres ( up to 50000 ) around each photo, 0 to find the nearest places by distance.")
+		keyword      = fs.String("keyword", "", "Only find places matching this keyword, such as a name or cuisine.")
+		placeType    = fs.String("type", "point_of_interest", "Only find places of this type, such as store or cafe, empty for any.")
+		format       = fs.String("format", "table", "Output format - table, csv or json.")
+		strict       = addStrictFlag(fs)
+		reportFlags  = addReportFlags(fs)
+		manifestFile = addManifestFlag(fs)
+	)
+	in, code := parseInputs(fs, args, manifestFile)
+	if code != exitOK {
+		return code
+	}
+	if *radius < 0 || *radius > 50000 {
+		fmt.Fprintf(fs.Output(), "Invalid radius - must be between 0 and 50000\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+	if *radius == 0 && *keyword == "" && *placeType == "" {
+		fmt.Fprintf(fs.Output(), "Finding the nearest places needs a keyword or type, or give a radius\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+	if !validChoice(*format, []string{"table", "csv", "json"}) {
+		fmt.Fprintf(fs.Output(), "Invalid format - must be one of table, csv or json\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+	if !reportFlags.valid(fs) {
+		return exitUsage
+	}
+
+	apiKey, err := valueOrFile(*keys.apikey, *keys.apiKeyFile)
+	if err != nil {
+		log.Printf("Unable to read API key: %v", err)
+		return exitError
+	}
+
+	runReport := report.New(fs.Name())
+	in.reportSkipped(runReport)
+	search := places.Search{Radius: *radius, Keyword: *keyword, Type: *placeType}
+	pois, err := places.ListPois(quota.Wrap(nil, *keys.rate), apiKey, search, in.files, runReport, in.tour, *strict)
+	if err == nil {
+		err = places.PrintPois(os.Stdout, pois, *format)
+	}
+	return reportFlags.finish(runReport, err)
+}
+
+func runUmap(fs *flag.FlagSet, args []string) int {
+	var (
+		outputDirectory = fs.String("output-dir", "umap", "Output directory for uMap files.")
+		webURL          = fs.String("web-url", "", "URL of web server that hosts photos for uMap server (required).")
+		strict          = addStrictFlag(fs)
+		reportFlags     = addReportFlags(fs)
+		manifestFile    = addManifestFlag(fs)
+	)
+	in, code := parseInputs(fs, args, manifestFile)
+	if code != exitOK {
+		return code
+	}
+	if !reportFlags.valid(fs) {
+		return exitUsage
+	}
+
+	// flags take priority over the manifest
+	//
+	if in.tour != nil && in.tour.Umap.OutputDir != "" && !isFlagSet(fs, "output-dir") {
+		*outputDirectory = in.tour.Umap.OutputDir
+	}
+	if in.tour != nil && in.tour.Umap.WebURL != "" && !isFlagSet(fs, "web-url") {
+		*webURL = in.tour.Umap.WebURL
+	}
+	if len(*webURL) == 0 {
+		fmt.Fprintf(fs.Output(), "Web URL must be provided\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+
+	runReport := report.New(fs.Name())
+	in.reportSkipped(runReport)
+	err := umap.Create(*outputDirectory, *webURL, in.files, runReport, in.tour, *strict)
+	return reportFlags.finish(runReport, err)
+}
+
+func runInspect(fs *flag.FlagSet, args []string) int {
+	manifestFile := addManifestFlag(fs)
+	in, code := parseInputs(fs, args, manifestFile)
+	if code != exitOK {
+		return code
+	}
+
+	tracks, hasTracks, err := track.MergeTemp(in.files)
+	if err != nil {
+		log.Printf("Unable to create tracks.gpx file - %v", err)
+		return exitError
+	}
+	defer os.Remove(tracks)
+
+	inspected, failed := 0, in.failed()
+	for _, imageFilename := range in.files {
+		if metadata.IsPhoto(imageFilename) {
+			fmt.Printf("%s:\n", imageFilename)
+			fmt.Printf("  360 photo: %t\n", metadata.Is360(imageFilename))
+			timestamp, lat, long, altitude, source, err := in.tour.Locate(imageFilename, tracks, hasTracks)
+			if err != nil {
+				fmt.Printf("  Error:     %v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("  Timestamp: %s\n", timestamp)
+			fmt.Printf("  Location:  %f, %f (from %s)\n", lat, long, source)
+			fmt.Printf("  Altitude:  %f\n", altitude)
+			inspected++
+		}
+	}
+	for _, skipped := range in.skipped {
+		if skipped.Failed {
+			fmt.Printf("%s:\n", skipped.File)
+			fmt.Printf("  Error:     %s\n", skipped.Reason)
+		}
+	}
+	return exitCode(inspected, failed)
+}
+
+func runValidate(fs *flag.FlagSet, args []string) int {
+	manifestFile := addManifestFlag(fs)
+	in, code := parseInputs(fs, args, manifestFile)
+	if code != exitOK {
+		return code
+	}
+
+	tracks, hasTracks, err := track.MergeTemp(in.files)
+	if err != nil {
+		log.Printf("Unable to read gpx files - %v", err)
+		return exitError
+	}
+	defer os.Remove(tracks)
+
+	invalid := in.failed()
+	for _, imageFilename := range in.files {
+		if metadata.IsPhoto(imageFilename) {
+			var problems []string
+			if !metadata.Is360(imageFilename) {
+				problems = append(problems, "not a 360 picture")
+			}
+			_, _, _, _, _, err := in.tour.Locate(imageFilename, tracks, hasTracks)
+			if err != nil {
+				problems = append(problems, err.Error())
+			}
+			if len(problems) > 0 {
+				fmt.Printf("%s: %s\n", imageFilename, strings.Join(problems, ", "))
+				invalid++
+			} else {
+				fmt.Printf("%s: ok\n", imageFilename)
+			}
+		}
+	}
+
+	if invalid > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+func runList(fs *flag.FlagSet, args []string) int {
+	var (
+		google = addGoogleFlags(fs)
+		format = fs.String("format", "table", "Output format - table, csv or geojson.")
+	)
+	code := parseFlags(fs, args)
+	if code != exitOK {
+		return code
+	}
+	if !google.valid(fs) {
+		return exitUsage
+	}
+	if *format != "table" && *format != "csv" && *format != "geojson" {
+		fmt.Fprintf(fs.Output(), "Invalid format - must be one of table, csv or geojson\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+
+	client, err := startOauth(google)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	var photos []*streetviewpublish.Photo
+	err = client.List(context.Background(), func(photo *streetviewpublish.Photo) {
+		photos = append(photos, photo)
+	})
+	if err != nil {
+		log.Printf("Unable to list photos: %v", err)
+		return exitError
+	}
+	err = streetview.PrintPhotos(os.Stdout, photos, *format)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	return exitOK
+}
+
+func runDelete(fs *flag.FlagSet, args []string) int {
+	var (
+		google      = addGoogleFlags(fs)
+		yes         = fs.Bool("yes", false, "don't ask for confirmation")
+		journalFile = fs.String("journal", "", "Delete the photos uploaded in this upload journal.")
+		reportFile  = fs.String("report", "", "Delete the photos uploaded in this upload report.")
+		bbox        = fs.String("bbox", "", "Only delete photos inside this bounding box - south,west,north,east in degrees.  A west greater than east crosses the antimeridian.")
+		from        = fs.String("from", "", "Only delete photos captured at or after this time - 2006-01-02 or 2006-01-02T15:04:05Z.")
+		to          = fs.String("to", "", "Only delete photos captured before this time, or on or before this date.")
+	)
+	code := parseFlags(fs, args)
+	if code != exitOK {
+		return code
+	}
+	if !google.valid(fs) {
+		return exitUsage
+	}
+
+	// photo ids to delete, from the arguments, journal and report
+	//
+	selection := streetview.Selection{PhotoIds: fs.Args()}
+	if *journalFile != "" {
+		photoIds, err := streetview.JournalPhotoIds(*journalFile)
+		if err != nil {
+			log.Printf("Unable to read journal: %v", err)
+			return exitError
+		}
+		selection.PhotoIds = append(selection.PhotoIds, photoIds...)
+	}
+	if *reportFile != "" {
+		r, err := report.Read(*reportFile)
+		if err != nil {
+			log.Printf("Unable to read report: %v", err)
+			return exitError
+		}
+		for _, entry := range r.Files {
+			if entry.PhotoId != "" {
+				selection.PhotoIds = append(selection.PhotoIds, entry.PhotoId)
+			}
+		}
+	}
+
+	// filters
+	//
+	var err error
+	if *bbox != "" {
+		selection.Bounds, err = parseBounds(*bbox)
+	}
+	if err == nil && *from != "" {
+		selection.From, err = parseTime(*from, false)
+	}
+	if err == nil && *to != "" {
+		selection.To, err = parseTime(*to, true)
+	}
+	if err != nil {
+		fmt.Fprintf(fs.Output(), "%v\n\n", err)
+		fs.Usage()
+		return exitUsage
+	}
+	if len(selection.PhotoIds) == 0 && selection.Bounds == nil && selection.From.IsZero() && selection.To.IsZero() {
+		if *journalFile != "" || *reportFile != "" {
+			log.Println("No uploaded photos found, nothing deleted")
+			return exitOK
+		}
+		fmt.Fprintf(fs.Output(), "No photo ids, journal, report, bounding box or time range supplied\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+
+	client, err := startOauth(google)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	photos, err := client.Select(context.Background(), selection)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	if len(photos) == 0 {
+		log.Println("No photos selected, nothing deleted")
+		return exitOK
+	}
+
+	var photoIds []string
+	for _, photo := range photos {
+		photoIds = append(photoIds, photo.PhotoId.Id)
+		fmt.Fprintf(os.Stderr, "%s %s %s\n", photo.PhotoId.Id, photo.CaptureTime, photo.ShareLink)
+	}
+	if !*yes && !confirm(fmt.Sprintf("Delete %d photos from Google Street View?", len(photoIds))) {
+		log.Println("Nothing deleted")
+		return exitOK
+	}
+
+	deleted, err := client.Delete(photoIds)
+	if *journalFile != "" && len(deleted) > 0 {
+		pruneErr := streetview.PruneJournal(*journalFile, deleted)
+		if pruneErr != nil {
+			log.Printf("Unable to remove deleted photos from journal: %v", pruneErr)
+			return exitError
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+	return exitOK
+}
+
+// parseBounds parses a bounding box - south,west,north,east.  West east of
+// east crosses the antimeridian
+func parseBounds(value string) (*streetview.Bounds, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid bounding box %s - must be south,west,north,east", value)
+	}
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bounding box %s - %v", value, err)
+		}
+		values[i] = v
+	}
+	bounds := &streetview.Bounds{South: values[0], West: values[1], North: values[2], East: values[3]}
+	if bounds.South > bounds.North {
+		return nil, fmt.Errorf("invalid bounding box %s - south must be below north", value)
+	}
+	if bounds.South < -90 || bounds.North > 90 || math.Abs(bounds.West) > 180 || math.Abs(bounds.East) > 180 {
+		return nil, fmt.Errorf("invalid bounding box %s - latitudes must be within 90 and longitudes 180 degrees", value)
+	}
+	return bounds, nil
+}
+
+// parseTime parses a time, or a date.  For the end of a range, a date
+// includes the whole day
+func parseTime(value string, end bool) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s - must be 2006-01-02 or 2006-01-02T15:04:05Z", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func confirm(prompt string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
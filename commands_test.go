@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/plord12/360tools/report"
+)
+
+func TestCommandsUnknown(t *testing.T) {
+	if findCommand("junk") != nil {
+		t.Errorf("found junk command")
+	}
+	if findCommand("upload") == nil {
+		t.Errorf("didn't find upload command")
+	}
+}
+
+func TestValidateNoArgs(t *testing.T) {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	if runValidate(fs, []string{}) != exitUsage {
+		t.Errorf("didn't fail with usage error")
+	}
+}
+
+func TestValidateGood(t *testing.T) {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	if runValidate(fs, []string{"testdata/3601.jpg", "testdata/nolocation.jpg", "testdata/good1.gpx"}) != exitOK {
+		t.Errorf("unexpected fail")
+	}
+}
+
+func TestValidateBad(t *testing.T) {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	if runValidate(fs, []string{"testdata/3601.jpg", "testdata/flat1.jpg", "testdata/nolocation.jpg"}) != exitError {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestFinishStatus(t *testing.T) {
+	fs := flag.NewFlagSet("umap", flag.ContinueOnError)
+	reportFlags := addReportFlags(fs)
+
+	r := report.New("umap")
+	r.Add(&report.Entry{File: "notes.txt", Outcome: report.Ignored, Error: "not a photo"})
+	r.Add(&report.Entry{File: "good.jpg", Outcome: report.Generated})
+	if reportFlags.finish(r, nil) != exitOK {
+		t.Errorf("unexpected fail")
+	}
+	r.Add(&report.Entry{File: "bad.jpg", Outcome: report.Skipped, Error: "no GPS data"})
+	if reportFlags.finish(r, nil) != exitPartial {
+		t.Errorf("didn't partially fail")
+	}
+	if reportFlags.finish(r, errors.New("aborted")) != exitError {
+		t.Errorf("didn't fail")
+	}
+
+	r = report.New("umap")
+	r.Add(&report.Entry{File: "bad.jpg", Outcome: report.Skipped, Error: "no GPS data"})
+	if reportFlags.finish(r, nil) != exitError {
+		t.Errorf("didn't fail")
+	}
+
+	// nothing processed
+	//
+	r = report.New("umap")
+	r.Add(&report.Entry{File: "notes.txt", Outcome: report.Ignored, Error: "not a photo"})
+	if reportFlags.finish(r, nil) != exitError {
+		t.Errorf("didn't fail with no files")
+	}
+}
+
+func TestParseInputsMissing(t *testing.T) {
+	fs := flag.NewFlagSet("umap", flag.ContinueOnError)
+	addStrictFlag(fs)
+	reportFlags := addReportFlags(fs)
+	manifestFile := addManifestFlag(fs)
+	in, code := parseInputs(fs, []string{"testdata/3601.jpg", "testdata/typo.jpg"}, manifestFile)
+	if code != exitOK || len(in.files) != 1 || in.failed() != 1 {
+		t.Fatalf("unexpected inputs %v %d", in, code)
+	}
+
+	// a missing file fails, so the run is only partly ok
+	//
+	r := report.New("umap")
+	in.reportSkipped(r)
+	r.Add(&report.Entry{File: "testdata/3601.jpg", Outcome: report.Generated})
+	if r.Files[0].Outcome != report.Failed || reportFlags.finish(r, nil) != exitPartial {
+		t.Errorf("missing file didn't fail %v", r.Files[0])
+	}
+
+	// strict stops at it
+	//
+	fs = flag.NewFlagSet("umap", flag.ContinueOnError)
+	strict := addStrictFlag(fs)
+	manifestFile = addManifestFlag(fs)
+	_, code = parseInputs(fs, []string{"--strict", "testdata/3601.jpg", "testdata/typo.jpg"}, manifestFile)
+	if !*strict || code != exitError {
+		t.Errorf("strict didn't fail %d", code)
+	}
+}
+
+func TestValidateMissing(t *testing.T) {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	if runValidate(fs, []string{"testdata/3601.jpg", "testdata/typo.jpg"}) != exitError {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestDeleteNoSelection(t *testing.T) {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	if runDelete(fs, []string{}) != exitUsage {
+		t.Errorf("didn't fail with usage error")
+	}
+	fs = flag.NewFlagSet("delete", flag.ContinueOnError)
+	if runDelete(fs, []string{"--bbox", "52,-1,51,0"}) != exitUsage {
+		t.Errorf("didn't fail with usage error")
+	}
+}
+
+func TestParseBounds(t *testing.T) {
+	bounds, err := parseBounds("51.4, -0.9, 51.5, -0.8")
+	if err != nil || bounds.South != 51.4 || bounds.West != -0.9 || bounds.North != 51.5 || bounds.East != -0.8 {
+		t.Errorf("unexpected bounds %v - %v", bounds, err)
+	}
+	bounds, err = parseBounds("-20,170,-10,-170")
+	if err != nil || bounds.West != 170 || bounds.East != -170 {
+		t.Errorf("unexpected antimeridian bounds %v - %v", bounds, err)
+	}
+	for _, value := range []string{"51.4,-0.9,51.5", "51.4,x,51.5,-0.8", "51.5,-0.9,51.4,-0.8", "51.4,-190,51.5,-0.8", "89,0,91,1"} {
+		_, err = parseBounds(value)
+		if err == nil {
+			t.Errorf("%s didn't fail", value)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	from, err := parseTime("2022-10-22", false)
+	if err != nil || !from.Equal(time.Date(2022, time.October, 22, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time %s - %v", from, err)
+	}
+	to, err := parseTime("2022-10-22", true)
+	if err != nil || !to.Equal(time.Date(2022, time.October, 23, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time %s - %v", to, err)
+	}
+	at, err := parseTime("2022-10-22T08:10:00Z", true)
+	if err != nil || !at.Equal(time.Date(2022, time.October, 22, 8, 10, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time %s - %v", at, err)
+	}
+	_, err = parseTime("yesterday", false)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestPoisInvalidFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"--radius", "-1", "testdata/3601.jpg"},
+		{"--type", "", "testdata/3601.jpg"},
+		{"--format", "xml", "testdata/3601.jpg"},
+	} {
+		fs := flag.NewFlagSet("pois", flag.ContinueOnError)
+		if runPois(fs, args) != exitUsage {
+			t.Errorf("%v didn't fail with usage error", args)
+		}
+	}
+}
+
+func TestDryRunMissing(t *testing.T) {
+	journal := path.Join(t.TempDir(), "journal.json")
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	if runUpload(fs, []string{"--dry-run", "--journal", journal, "testdata/typo.jpg"}) != exitError {
+		t.Errorf("didn't fail")
+	}
+	fs = flag.NewFlagSet("upload", flag.ContinueOnError)
+	if runUpload(fs, []string{"--dry-run", "--journal", journal, "testdata/3601.jpg", "testdata/typo.jpg"}) != exitPartial {
+		t.Errorf("didn't partially fail")
+	}
+}
+
+func TestInspectMissing(t *testing.T) {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	if runInspect(fs, []string{"testdata/typo.jpg"}) != exitError {
+		t.Errorf("didn't fail")
+	}
+	fs = flag.NewFlagSet("inspect", flag.ContinueOnError)
+	if runInspect(fs, []string{"testdata/3601.jpg", "testdata/typo.jpg"}) != exitPartial {
+		t.Errorf("didn't partially fail")
+	}
+}
@@ -0,0 +1,132 @@
+// config file functions
+//
+// The config file holds named profiles, for example for different Google
+// accounts -
+//
+//	defaultProfile: home
+//	profiles:
+//	  home:
+//	    clientIdFile: home/clientid.dat
+//	    secretFile: home/clientsecret.dat
+//	    apiKeyFile: home/apikey.dat
+//	  work:
+//	    clientId: 1234.apps.googleusercontent.com
+//	    secret: abcd
+//	    apiKey: efgh
+//	    outputDir: /var/www/umap
+//	    webUrl: https://example.com/umap
+//
+// Profile values are used for any flags not given on the command line.  File
+// names are relative to the config file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+type profile struct {
+	ClientID            string `yaml:"clientId"`
+	ClientIDFile        string `yaml:"clientIdFile"`
+	Secret              string `yaml:"secret"`
+	SecretFile          string `yaml:"secretFile"`
+	ClientSecretFile    string `yaml:"clientSecretFile"`
+	TokenPassphraseFile string `yaml:"tokenPassphraseFile"`
+	APIKey              string `yaml:"apiKey"`
+	APIKeyFile          string `yaml:"apiKeyFile"`
+	OutputDir           string `yaml:"outputDir"`
+	WebURL              string `yaml:"webUrl"`
+}
+
+type config struct {
+	DefaultProfile string              `yaml:"defaultProfile"`
+	Profiles       map[string]*profile `yaml:"profiles"`
+	dir            string
+}
+
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "360tools", "config.yaml")
+}
+
+// loadConfig reads the config file.  A missing file is only an error if
+// it was asked for
+func loadConfig(filename string, required bool) (*config, error) {
+	c := &config{dir: filepath.Dir(filename)}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) && !required {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return c, nil
+}
+
+// profile returns the named profile, or the default profile if name is
+// empty.  There need not be a default profile
+func (c *config) profile(name string) (*profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+		if name == "" {
+			name = "default"
+		}
+		if c.Profiles[name] == nil {
+			return &profile{}, nil
+		}
+	}
+	p := c.Profiles[name]
+	if p == nil {
+		return nil, fmt.Errorf("no profile %s in config file", name)
+	}
+	return p, nil
+}
+
+// apply sets any flags that the command has, but weren't given on the
+// command line, from the profile.  The flags are not marked as set, so the
+// command line, then manifests, take priority
+func (c *config) apply(p *profile, fs *flag.FlagSet) error {
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(c.dir, file)
+	}
+	values := map[string]string{
+		"clientid":              p.ClientID,
+		"clientid-file":         resolve(p.ClientIDFile),
+		"secret":                p.Secret,
+		"secret-file":           resolve(p.SecretFile),
+		"client-secret-file":    resolve(p.ClientSecretFile),
+		"token-passphrase-file": resolve(p.TokenPassphraseFile),
+		"apikey":                p.APIKey,
+		"apikey-file":           resolve(p.APIKeyFile),
+		"output-dir":            p.OutputDir,
+		"web-url":               p.WebURL,
+	}
+	for name, value := range values {
+		f := fs.Lookup(name)
+		if f == nil || value == "" || isFlagSet(fs, name) || isFlagSet(fs, name+"-file") {
+			continue
+		}
+		err := f.Value.Set(value)
+		if err != nil {
+			return fmt.Errorf("invalid profile value for %s: %v", name, err)
+		}
+	}
+	return nil
+}
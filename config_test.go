@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"path"
+	"testing"
+)
+
+func TestConfigNoSuchFile(t *testing.T) {
+	c, err := loadConfig("junk.yaml", false)
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	p, err := c.profile("")
+	if err != nil || *p != (profile{}) {
+		t.Errorf("unexpected default profile %v - %v", p, err)
+	}
+
+	_, err = loadConfig("junk.yaml", true)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestConfigJunkFile(t *testing.T) {
+	_, err := loadConfig("testdata/junk.gpx", false)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	c, err := loadConfig("testdata/config.yaml", true)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	p, err := c.profile("")
+	if err != nil || p.WebURL != "http://server/home" {
+		t.Errorf("unexpected default profile %v - %v", p, err)
+	}
+	p, err = c.profile("work")
+	if err != nil || p.ClientID != "work-client" {
+		t.Errorf("unexpected work profile %v - %v", p, err)
+	}
+	_, err = c.profile("junk")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	c, _ := loadConfig("testdata/config.yaml", true)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	google := addGoogleFlags(fs)
+	webURL := fs.String("web-url", "", "")
+	outputDir := fs.String("output-dir", "umap", "")
+	fs.Parse([]string{"--web-url", "http://server/flag"})
+
+	p, _ := c.profile("")
+	err := c.apply(p, fs)
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if *webURL != "http://server/flag" {
+		t.Errorf("command line not used %s", *webURL)
+	}
+	if *google.clientIDFile != path.Join("testdata", "home", "clientid.dat") {
+		t.Errorf("profile file not used %s", *google.clientIDFile)
+	}
+	if *outputDir != "umap" {
+		t.Errorf("default not used %s", *outputDir)
+	}
+	if isFlagSet(fs, "clientid-file") {
+		t.Errorf("profile flag marked as set")
+	}
+}
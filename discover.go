@@ -0,0 +1,133 @@
+// input discovery functions
+//
+// Arguments can be photos, gpx tracks, directories ( searched recursively ),
+// @file to read a list of files from a file, or - to read a list of files
+// from stdin.  Lists have one file per line, blank lines and lines starting
+// with # are ignored.  Files found in directories that aren't photos or gpx
+// tracks are ignored, but files named explicitly that are missing or of the
+// wrong type fail.  A file found more than once, such as named and in a
+// directory, is only used the first time
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/plord12/360tools/metadata"
+	"github.com/plord12/360tools/track"
+)
+
+// skippedFile is an input that won't be processed - failed if it was named
+// explicitly, or couldn't be read
+type skippedFile struct {
+	File   string
+	Reason string
+	Failed bool
+}
+
+func discoverInputs(args []string, stdin io.Reader) ([]string, []skippedFile, error) {
+	var files []string
+	var skipped []skippedFile
+
+	// files are kept by absolute path, so each is only used once
+	//
+	seen := map[string]bool{}
+	first := func(file string) bool {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			abs = filepath.Clean(file)
+		}
+		if seen[abs] {
+			return false
+		}
+		seen[abs] = true
+		return true
+	}
+	found := func(file string) {
+		if first(file) {
+			files = append(files, file)
+		}
+	}
+	skip := func(file string, reason string, failed bool) {
+		if first(file) {
+			skipped = append(skipped, skippedFile{File: file, Reason: reason, Failed: failed})
+		}
+	}
+
+	var add func(arg string, fromList bool) error
+	add = func(arg string, fromList bool) error {
+
+		// lists of files
+		//
+		if !fromList && (arg == "-" || strings.HasPrefix(arg, "@")) {
+			var r io.Reader = stdin
+			if arg != "-" {
+				f, err := os.Open(arg[1:])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				err := add(line, true)
+				if err != nil {
+					return err
+				}
+			}
+			return scanner.Err()
+		}
+
+		info, err := os.Stat(arg)
+		if err != nil {
+			skip(arg, err.Error(), true)
+			return nil
+		}
+
+		// directories, in name order
+		//
+		if info.IsDir() {
+			return filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					skip(path, err.Error(), true)
+					return nil
+				}
+				if d.IsDir() {
+					return nil
+				}
+				if metadata.IsPhoto(path) || track.IsTrack(path) {
+					found(path)
+				} else {
+					skip(path, "not a photo or gpx track", false)
+				}
+				return nil
+			})
+		}
+
+		if metadata.IsPhoto(arg) || track.IsTrack(arg) {
+			found(arg)
+		} else {
+			skip(arg, fmt.Sprintf("unsupported file type %q", filepath.Ext(arg)), true)
+		}
+		return nil
+	}
+
+	for _, arg := range args {
+		err := add(arg, false)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return files, skipped, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(path.Join(dir, "sub"), 0755)
+	for _, file := range []string{"b.JPEG", "a.jpg", "notes.txt", "sub/c.insp", "sub/track.GPX"} {
+		os.WriteFile(path.Join(dir, file), []byte{}, 0644)
+	}
+
+	files, skipped, err := discoverInputs([]string{dir, path.Join(dir, "missing.jpg")}, nil)
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	expected := []string{"a.jpg", "b.JPEG", "sub/c.insp", "sub/track.GPX"}
+	if len(files) != len(expected) {
+		t.Fatalf("unexpected files %v", files)
+	}
+	for i := range expected {
+		if files[i] != path.Join(dir, expected[i]) {
+			t.Errorf("unexpected file %s", files[i])
+		}
+	}
+	if len(skipped) != 2 || skipped[0].File != path.Join(dir, "notes.txt") || skipped[1].File != path.Join(dir, "missing.jpg") {
+		t.Errorf("unexpected skipped files %v", skipped)
+	}
+
+	// only the missing file named explicitly fails
+	//
+	if skipped[0].Failed || !skipped[1].Failed {
+		t.Errorf("unexpected failed files %v", skipped)
+	}
+}
+
+func TestDiscoverLists(t *testing.T) {
+	stdin := strings.NewReader("testdata/3601.jpg\n\n# comment\ntestdata/tour.yaml\n")
+	files, skipped, err := discoverInputs([]string{"-", "testdata/good1.gpx"}, stdin)
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if len(files) != 2 || files[0] != "testdata/3601.jpg" || files[1] != "testdata/good1.gpx" {
+		t.Errorf("unexpected files %v", files)
+	}
+	if len(skipped) != 1 || skipped[0].File != "testdata/tour.yaml" || skipped[0].Reason == "" {
+		t.Errorf("unexpected skipped files %v", skipped)
+	}
+
+	list := path.Join(t.TempDir(), "list.txt")
+	os.WriteFile(list, []byte("testdata/nolocation.jpg\n"), 0644)
+	files, _, err = discoverInputs([]string{"@" + list}, nil)
+	if err != nil || len(files) != 1 || files[0] != "testdata/nolocation.jpg" {
+		t.Errorf("unexpected files %v - %v", files, err)
+	}
+
+	_, _, err = discoverInputs([]string{"@junk.txt"}, nil)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestDiscoverDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"a.jpg", "b.jpg", "notes.txt"} {
+		os.WriteFile(path.Join(dir, file), []byte{}, 0644)
+	}
+	list := path.Join(dir, "list.txt")
+	os.WriteFile(list, []byte(path.Join(dir, "a.jpg")+"\n"), 0644)
+
+	// the same files named, in a directory, a list and by another path
+	//
+	files, skipped, err := discoverInputs([]string{path.Join(dir, "b.jpg"), dir, "@" + list, dir + "/./a.jpg"}, nil)
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if len(files) != 2 || files[0] != path.Join(dir, "b.jpg") || files[1] != path.Join(dir, "a.jpg") {
+		t.Errorf("unexpected files %v", files)
+	}
+	if len(skipped) != 2 {
+		t.Errorf("unexpected skipped files %v", skipped)
+	}
+}
@@ -0,0 +1,35 @@
+// Package geo provides distance and bearing calculations on the WGS84
+// ellipsoid.  Latitudes, longitudes and bearings are in degrees, distances
+// in metres.
+package geo
+
+import (
+	"github.com/StefanSchroeder/Golang-Ellipsoid/ellipsoid"
+)
+
+func wgs84() ellipsoid.Ellipsoid {
+	return ellipsoid.Init("WGS84", ellipsoid.Degrees, ellipsoid.Meter, ellipsoid.LongitudeIsSymmetric, ellipsoid.BearingIsSymmetric)
+}
+
+// Bearing returns the bearing from the first point to the second, from 0
+// to 360 degrees clockwise from north
+func Bearing(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	_, bearing := wgs84().To(lat1, lon1, lat2, lon2)
+	if bearing < 0 {
+		bearing = bearing + 360
+	}
+	return bearing
+}
+
+// Displacement returns the east and north distances from the first point to
+// the second
+func Displacement(lat1 float64, lon1 float64, lat2 float64, lon2 float64) (float64, float64) {
+	x, y := wgs84().Displacement(lat1, lon1, lat2, lon2)
+	return x, y
+}
+
+// Location returns the point x metres east and y metres north of a point
+func Location(lat float64, lon float64, x float64, y float64) (float64, float64) {
+	newLat, newLon := wgs84().Location(lat, lon, x, y)
+	return newLat, newLon
+}
@@ -0,0 +1,23 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBearing(t *testing.T) {
+	if math.Abs(Bearing(51.0, -1.0, 52.0, -1.0)) > 0.01 {
+		t.Errorf("unexpected bearing north %f", Bearing(51.0, -1.0, 52.0, -1.0))
+	}
+	if math.Abs(Bearing(51.0, -1.0, 51.0, -2.0)-270) > 1 {
+		t.Errorf("unexpected bearing west %f", Bearing(51.0, -1.0, 51.0, -2.0))
+	}
+}
+
+func TestLocation(t *testing.T) {
+	x, y := Displacement(51.0, -1.0, 51.001, -1.001)
+	lat, lon := Location(51.0, -1.0, x, y)
+	if math.Abs(lat-51.001) > 0.000001 || math.Abs(lon+1.001) > 0.000001 {
+		t.Errorf("unexpected location %f, %f", lat, lon)
+	}
+}
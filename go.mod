@@ -2,10 +2,14 @@ module github.com/plord12/360tools
 
 go 1.17
 
-require github.com/StefanSchroeder/Golang-Ellipsoid v0.0.0-20221004092235-f00a9ab04789
+require (
+	github.com/StefanSchroeder/Golang-Ellipsoid v0.0.0-20221004092235-f00a9ab04789
+	github.com/evanoberholster/imagemeta v0.3.0
+	golang.org/x/crypto v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/evanoberholster/imagemeta v0.3.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
 	github.com/philhofer/fwd v1.1.2 // indirect
@@ -22,14 +26,13 @@ require (
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/googleapis/enterprise-certificate-proxy v0.2.3 // indirect
 	github.com/googleapis/gax-go/v2 v2.7.1 // indirect
-	github.com/tkrajina/gpxgo v1.2.1 // indirect
+	github.com/tkrajina/gpxgo v1.2.1
 	go.opencensus.io v0.24.0 // indirect
-	golang.org/x/image v0.9.0
 	golang.org/x/net v0.8.0 // indirect
-	golang.org/x/oauth2 v0.6.0 // indirect
+	golang.org/x/oauth2 v0.6.0
 	golang.org/x/sys v0.6.0 // indirect
 	golang.org/x/text v0.11.0 // indirect
-	google.golang.org/api v0.114.0 // indirect
+	google.golang.org/api v0.114.0
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto v0.0.0-20230306155012-7f2fa6fef1f4 // indirect
 	google.golang.org/grpc v1.53.0 // indirect
@@ -12,62 +12,360 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"github.com/plord12/360tools/manifest"
+	"github.com/plord12/360tools/report"
+)
+
+// exit codes
+const (
+	exitOK      = 0
+	exitError   = 1
+	exitUsage   = 2
+	exitPartial = 3
 )
 
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(fs *flag.FlagSet, args []string) int
+}
+
+var commands = []command{
+	{name: "upload", args: "[photos] [gpx files] [directories] [@list files]", description: "Upload 360 photos to Google Street View, connecting each photo to the next.", run: runUpload},
+	{name: "pois", args: "[photos] [directories] [@list files]", description: "List the points of interest nearest to the photos - requires api key.", run: runPois},
+	{name: "umap", args: "[photos] [gpx files] [directories] [@list files]", description: "Generate OpenStreetMap uMap files for photos hosted on a web server.", run: runUmap},
+	{name: "inspect", args: "[photos] [gpx files] [directories] [@list files]", description: "Show the metadata that would be used for each photo.", run: runInspect},
+	{name: "list", args: "", description: "List the photos published to Google Street View by the authenticated account.", run: runList},
+	{name: "delete", args: "[photo ids]", description: "Delete photos from Google Street View, by id, upload journal or report, area or capture time.", run: runDelete},
+	{name: "validate", args: "[photos] [gpx files] [directories] [@list files]", description: "Check that photos are 360 photos with a known location.", run: runValidate},
+}
+
 func main() {
-	// Flags
-	var (
-		clientID     = flag.String("clientid", "", "Google OAuth 2.0 Client ID.  If non-empty, overrides --clientid_file")
-		clientIDFile = flag.String("clientid-file", "clientid.dat",
-			"Name of a file containing just the project's Google OAuth 2.0 Client ID from https://developers.google.com/console.")
-		secret     = flag.String("secret", "", "Google OAuth 2.0 Client Secret.  If non-empty, overrides --secret_file")
-		secretFile = flag.String("secret-file", "clientsecret.dat",
-			"Name of a file containing just the project's OAuth 2.0 Client Secret from https://developers.google.com/console.")
-		apikey     = flag.String("apikey", "", "Google API key.  If non-empty, overrides --apikey_file")
-		apiKeyFile = flag.String("apikey-file", "apikey.dat",
-			"Name of a file containing just the project's Google API key from https://developers.google.com/console.")
-		cacheToken      = flag.Bool("cachetoken", true, "cache the Google OAuth 2.0 token")
-		pois            = flag.Bool("pois", false, "only list the nearest points of interest - requires api token")
-		skipConnections = flag.Bool("skip-connections", false, "skip Google Maps connections")
-		placeId         = flag.String("placeid", "", "place id (from --pois output) to add to upload")
-		mapType         = flag.String("map-type", "google", "Map type - google, for Google Street View, umap for OpenStreetMap uMap.")
-		outputDirectory = flag.String("output-dir", "umap", "Output directory for uMap files.")
-		webURL          = flag.String("web-url", "", "URL of web server that hosts photos for uMap server.")
-	)
-	flag.Usage = func() {
-		fmt.Printf("Tools to upload 360 images to Google Maps and OpenStreetMap uMap\n\nUsage: %s [flags] [jpg files] [gpx files]\n\nWhere [flags] can be:\n\n", os.Args[0])
-		flag.PrintDefaults()
-	}
-	flag.Parse()
-	if flag.NArg() == 0 {
-		log.Println("No jpgs supplied")
-		flag.PrintDefaults()
-		os.Exit(1)
-	}
-	if *pois {
-		listPois(apikey, apiKeyFile, flag.Args())
-		os.Exit(0)
-	}
-
-	if *mapType == "google" {
-		uploadGoogleMaps(clientID, clientIDFile, secret, secretFile, apikey, apiKeyFile, cacheToken, skipConnections, placeId, flag.Args())
-	} else if *mapType == "umap" {
-		if len(*webURL) == 0 {
-			log.Println("Web URL must be provided")
-			flag.PrintDefaults()
-			os.Exit(1)
+	name := filepath.Base(os.Args[0])
+
+	if len(os.Args) < 2 {
+		usage(name)
+		os.Exit(exitUsage)
+	}
+
+	switch os.Args[1] {
+	case "help", "-h", "-help", "--help":
+		if len(os.Args) > 2 {
+			if cmd := findCommand(os.Args[2]); cmd != nil {
+				// flags are defined by the command, so let it print its own usage
+				os.Exit(cmd.run(newFlagSet(name, cmd), []string{"-help"}))
+			}
 		}
-		err := createUmapFiles(outputDirectory, webURL, flag.Args())
-		if err != nil {
-			log.Println(err)
-			os.Exit(1)
+		usage(name)
+		os.Exit(exitOK)
+	}
+
+	cmd := findCommand(os.Args[1])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+		usage(name)
+		os.Exit(exitUsage)
+	}
+	os.Exit(cmd.run(newFlagSet(name, cmd), os.Args[2:]))
+}
+
+func usage(name string) {
+	fmt.Fprintf(os.Stderr, "Tools to upload 360 images to Google Maps and OpenStreetMap uMap\n\nUsage: %s <command> [flags] [arguments]\n\nWhere <command> is one of:\n\n", name)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse \"%s help <command>\" for the flags of a command.\n", name)
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func newFlagSet(name string, cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	fs.String("profile", "", "Profile in the config file to use for flags not given on the command line.")
+	fs.String("config", defaultConfigFile(), "Config file holding profiles.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "%s\n\nUsage: %s %s [flags] %s\n\nWhere [flags] can be:\n\n", cmd.description, name, cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// googleFlags are the OAuth flags shared by commands that talk to Google Street View
+type googleFlags struct {
+	clientID       *string
+	clientIDFile   *string
+	secret         *string
+	secretFile     *string
+	cacheToken     *bool
+	clientSecret   *string
+	passphrase     *string
+	passphraseFile *string
+	authMode       *string
+	authPort       *int
+	rate           *float64
+}
+
+func addGoogleFlags(fs *flag.FlagSet) *googleFlags {
+	return &googleFlags{
+		clientID: fs.String("clientid", "", "Google OAuth 2.0 Client ID.  If non-empty, overrides --clientid-file"),
+		clientIDFile: fs.String("clientid-file", "clientid.dat",
+			"Name of a file containing just the project's Google OAuth 2.0 Client ID from https://developers.google.com/console."),
+		secret: fs.String("secret", "", "Google OAuth 2.0 Client Secret.  If non-empty, overrides --secret-file"),
+		secretFile: fs.String("secret-file", "clientsecret.dat",
+			"Name of a file containing just the project's OAuth 2.0 Client Secret from https://developers.google.com/console."),
+		clientSecret: fs.String("client-secret-file", "client_secret.json",
+			"Name of the OAuth 2.0 client JSON file downloaded from https://developers.google.com/console.  Used if it exists, unless --clientid or --secret is given, instead of --clientid-file and --secret-file."),
+		cacheToken:     fs.Bool("cachetoken", true, "cache the Google OAuth 2.0 token"),
+		passphrase:     fs.String("token-passphrase", "", "Encrypt the cached token with this passphrase.  If non-empty, overrides --token-passphrase-file"),
+		passphraseFile: fs.String("token-passphrase-file", "", "Name of a file containing just the passphrase to encrypt the cached token with."),
+		authMode: fs.String("auth-mode", authBrowser, "How to authorize with Google when there is no cached token - "+strings.Join(authModes, ", ")+
+			".  loopback waits for the redirect without opening a browser, for example through an ssh tunnel, and manual asks for the redirected address or code to be pasted."),
+		authPort: fs.Int("auth-port", 0, "Port on 127.0.0.1 for the authorization redirect, 0 for any."),
+		rate:     addRateFlag(fs),
+	}
+}
+
+func (g *googleFlags) valid(fs *flag.FlagSet) bool {
+	if !validChoice(*g.authMode, authModes) {
+		fmt.Fprintf(fs.Output(), "Invalid auth mode - must be one of %s\n\n", strings.Join(authModes, ", "))
+		fs.Usage()
+		return false
+	}
+	return true
+}
+
+// apiKeyFlags are the flags for commands that use the Google Places API
+type apiKeyFlags struct {
+	apikey     *string
+	apiKeyFile *string
+	rate       *float64
+}
+
+// addAPIKeyFlags defines the API key flags, sharing rate if the command
+// already has --rate
+func addAPIKeyFlags(fs *flag.FlagSet, rate *float64) *apiKeyFlags {
+	if rate == nil {
+		rate = addRateFlag(fs)
+	}
+	return &apiKeyFlags{
+		apikey: fs.String("apikey", "", "Google API key.  If non-empty, overrides --apikey-file"),
+		apiKeyFile: fs.String("apikey-file", "apikey.dat",
+			"Name of a file containing just the project's Google API key from https://developers.google.com/console."),
+		rate: rate,
+	}
+}
+
+// addRateFlag defines --rate, the limit on requests to Google.  Requests
+// over quota or failing on Google's side are retried whatever the limit
+func addRateFlag(fs *flag.FlagSet) *float64 {
+	return fs.Float64("rate", 5, "Maximum requests per second to Google, 0 for no limit.")
+}
+
+// reportFlags are the flags for commands that can write a run report
+type reportFlags struct {
+	file   *string
+	format *string
+}
+
+func addReportFlags(fs *flag.FlagSet) *reportFlags {
+	return &reportFlags{
+		file:   fs.String("report", "", "Write a machine readable report of the outcome for each file to this file."),
+		format: fs.String("report-format", "json", "Format of the --report file - json or jsonl (one line per file)."),
+	}
+}
+
+func (r *reportFlags) valid(fs *flag.FlagSet) bool {
+	if *r.format != "json" && *r.format != "jsonl" {
+		fmt.Fprintf(fs.Output(), "Invalid report format - must be one of json or jsonl\n\n")
+		fs.Usage()
+		return false
+	}
+	return true
+}
+
+// validChoice returns true if value is one of choices
+func validChoice(value string, choices []string) bool {
+	for _, choice := range choices {
+		if value == choice {
+			return true
+		}
+	}
+	return false
+}
+
+// finish logs any error for the whole run, prints the summary of each file
+// and writes the report if one was asked for.  It returns the exit status -
+// ok if every file succeeded, partial if only some did, and error if none
+// did ( including when there were none ) or the run failed
+func (r *reportFlags) finish(runReport *report.Report, err error) int {
+	if err != nil {
+		log.Println(err)
+	}
+
+	fmt.Fprintln(os.Stderr)
+	runReport.Summary(os.Stderr)
+
+	if *r.file != "" {
+		writeErr := report.Write(runReport, *r.file, *r.format)
+		if writeErr != nil {
+			log.Printf("Unable to write report %s: %v", *r.file, writeErr)
+			return exitError
+		}
+	}
+
+	if err != nil {
+		return exitError
+	}
+	return exitCode(runReport.Counts())
+}
+
+// exitCode returns the exit code for ok files processed and failed files
+// that couldn't be - an error if nothing was processed
+func exitCode(ok int, failed int) int {
+	switch {
+	case ok == 0:
+		return exitError
+	case failed == 0:
+		return exitOK
+	}
+	return exitPartial
+}
+
+func addStrictFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("strict", false, "Stop at the first file that can't be processed, rather than skipping it.")
+}
+
+// inputs are the photos and tracks a command should process
+type inputs struct {
+	files   []string
+	tour    *manifest.Manifest
+	skipped []skippedFile
+}
+
+// parseInputs parses the command flags, returning the photos and tracks to
+// process - from the manifest if one is given, otherwise the arguments.
+// Ignored and failed files are logged with the reason, and with --strict a
+// failed file is an error
+func parseInputs(fs *flag.FlagSet, args []string, manifestFile *string) (*inputs, int) {
+	code := parseFlags(fs, args)
+	if code != exitOK {
+		return nil, code
+	}
+
+	in := &inputs{}
+	files := fs.Args()
+	if *manifestFile == "" {
+		if fs.NArg() == 0 {
+			fmt.Fprintf(fs.Output(), "No jpgs or manifest supplied\n\n")
+			fs.Usage()
+			return nil, exitUsage
 		}
 	} else {
-		log.Println("Invalid map type - must be one of google or umap")
-		flag.PrintDefaults()
-		os.Exit(1)
+		if fs.NArg() > 0 {
+			fmt.Fprintf(fs.Output(), "Files can't be supplied with a manifest\n\n")
+			fs.Usage()
+			return nil, exitUsage
+		}
+		tour, err := manifest.Load(*manifestFile)
+		if err != nil {
+			log.Printf("Unable to read manifest: %v", err)
+			return nil, exitError
+		}
+		in.tour = tour
+		files = tour.Files()
+	}
+
+	var err error
+	in.files, in.skipped, err = discoverInputs(files, os.Stdin)
+	if err != nil {
+		log.Printf("Unable to read file list: %v", err)
+		return nil, exitError
+	}
+	strict := fs.Lookup("strict")
+	for _, skipped := range in.skipped {
+		if !skipped.Failed {
+			log.Printf("%s: ignored - %s", skipped.File, skipped.Reason)
+			continue
+		}
+		log.Printf("%s: failed - %s", skipped.File, skipped.Reason)
+		if strict != nil && strict.Value.String() == "true" {
+			return nil, exitError
+		}
+	}
+	return in, exitOK
+}
+
+// failed returns the number of inputs that couldn't be read
+func (in *inputs) failed() int {
+	failed := 0
+	for _, skipped := range in.skipped {
+		if skipped.Failed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// reportSkipped adds the ignored and failed inputs to a run report
+func (in *inputs) reportSkipped(runReport *report.Report) {
+	for _, skipped := range in.skipped {
+		outcome := report.Ignored
+		if skipped.Failed {
+			outcome = report.Failed
+		}
+		runReport.Add(&report.Entry{File: skipped.File, Outcome: outcome, Error: skipped.Reason})
+	}
+}
+
+func addManifestFlag(fs *flag.FlagSet) *string {
+	return fs.String("manifest", "", "Manifest file describing the tour ( photos, places, overrides, tracks and connections ), used instead of listing files.")
+}
+
+// isFlagSet returns true if a flag was given on the command line
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// parseFlags parses the command flags, then fills in any flags not given on
+// the command line from the profile
+func parseFlags(fs *flag.FlagSet, args []string) int {
+	fs.Parse(args)
+
+	profileFlag, configFlag := fs.Lookup("profile"), fs.Lookup("config")
+	if profileFlag == nil || configFlag == nil {
+		return exitOK
+	}
+	config, err := loadConfig(configFlag.Value.String(), isFlagSet(fs, "config"))
+	if err != nil {
+		log.Printf("Unable to read config file: %v", err)
+		return exitError
+	}
+	profile, err := config.profile(profileFlag.Value.String())
+	if err == nil {
+		err = config.apply(profile, fs)
+	}
+	if err != nil {
+		log.Printf("Unable to use profile: %v", err)
+		return exitError
 	}
+	return exitOK
 }
 
 func openURL(url string) {
@@ -81,13 +379,14 @@ func openURL(url string) {
 	log.Printf("Error opening URL in browser.")
 }
 
-func valueOrFileContents(value string, filename string) string {
-	if value != "" {
-		return value
+// valueOrFile returns value, or if empty the contents of filename if given
+func valueOrFile(value string, filename string) (string, error) {
+	if value != "" || filename == "" {
+		return value, nil
 	}
 	slurp, err := os.ReadFile(filename)
 	if err != nil {
-		log.Fatalf("Error reading %q: %v", filename, err)
+		return "", err
 	}
-	return strings.TrimSpace(string(slurp))
+	return strings.TrimSpace(string(slurp)), nil
 }
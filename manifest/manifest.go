@@ -0,0 +1,192 @@
+// Package manifest reads tour manifests.
+//
+// A manifest describes a tour in yaml ( or json ) - the photos in order,
+// with optional per photo place and location/heading overrides, gpx tracks,
+// umap settings and explicit connections -
+//
+//	name: Old Forest
+//	placeId: ChIJB2vKz_mDdkgRIKm50jzhTGk
+//	gpx:
+//	  - 2023-03-10_12-05_Fri.gpx
+//	photos:
+//	  - file: R0010165.JPG
+//	  - file: R0010166.JPG
+//	    heading: 45
+//	  - file: R0010167.JPG
+//	    latitude: 51.427622
+//	    longitude: -0.855147
+//	umap:
+//	  outputDir: umap
+//	  webUrl: https://plord.co.uk/360test
+//	connections:
+//	  - from: R0010165.JPG
+//	    to: R0010166.JPG
+//	  - from: R0010165.JPG
+//	    to: R0010167.JPG
+//
+// File names are relative to the manifest.  Without connections, each photo
+// is connected to the next as usual.
+
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/plord12/360tools/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// Photo is a photo in the tour, with any overrides of its own data
+type Photo struct {
+	File      string   `yaml:"file"`
+	PlaceId   string   `yaml:"placeId"`
+	Latitude  *float64 `yaml:"latitude"`
+	Longitude *float64 `yaml:"longitude"`
+	Altitude  *float64 `yaml:"altitude"`
+	Heading   *float64 `yaml:"heading"`
+}
+
+// Umap holds the umap command settings
+type Umap struct {
+	OutputDir string `yaml:"outputDir"`
+	WebURL    string `yaml:"webUrl"`
+}
+
+// Connection is a connection between two photos
+type Connection struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// Manifest is a tour.  A nil manifest is an empty tour, so methods can be
+// called without checking for one
+type Manifest struct {
+	// Name names the uMap map
+	Name        string       `yaml:"name"`
+	PlaceId     string       `yaml:"placeId"`
+	Gpx         []string     `yaml:"gpx"`
+	Photos      []*Photo     `yaml:"photos"`
+	Umap        Umap         `yaml:"umap"`
+	Connections []Connection `yaml:"connections"`
+}
+
+// Load reads a manifest, resolving file names relative to the manifest
+func Load(filename string) (*Manifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	err = yaml.Unmarshal(data, m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	// file names are relative to the manifest
+	//
+	dir := filepath.Dir(filename)
+	resolve := func(file string) string {
+		if filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, file)
+	}
+
+	known := map[string]bool{}
+	for i, photo := range m.Photos {
+		if photo.File == "" {
+			return nil, fmt.Errorf("%s: photo %d has no file", filename, i+1)
+		}
+		if (photo.Latitude == nil) != (photo.Longitude == nil) {
+			return nil, fmt.Errorf("%s: %s needs both latitude and longitude", filename, photo.File)
+		}
+		photo.File = resolve(photo.File)
+		known[photo.File] = true
+	}
+	for i := range m.Gpx {
+		m.Gpx[i] = resolve(m.Gpx[i])
+	}
+	for i := range m.Connections {
+		m.Connections[i].From = resolve(m.Connections[i].From)
+		m.Connections[i].To = resolve(m.Connections[i].To)
+		if !known[m.Connections[i].From] || !known[m.Connections[i].To] {
+			return nil, fmt.Errorf("%s: connection %d is not between photos in the manifest", filename, i+1)
+		}
+	}
+
+	return m, nil
+}
+
+// Files returns the photos and gpx tracks to process, in order
+func (m *Manifest) Files() []string {
+	var files []string
+	for _, photo := range m.Photos {
+		files = append(files, photo.File)
+	}
+	return append(files, m.Gpx...)
+}
+
+// Lookup returns the manifest entry for a photo, or nil
+func (m *Manifest) Lookup(file string) *Photo {
+	if m == nil {
+		return nil
+	}
+	for _, photo := range m.Photos {
+		if photo.File == file {
+			return photo
+		}
+	}
+	return nil
+}
+
+// PlaceIdFor returns the place for a photo - from the photo in the manifest,
+// the given default or the manifest default, in that order
+func (m *Manifest) PlaceIdFor(file string, placeId string) string {
+	if photo := m.Lookup(file); photo != nil && photo.PlaceId != "" {
+		return photo.PlaceId
+	}
+	if placeId == "" && m != nil {
+		return m.PlaceId
+	}
+	return placeId
+}
+
+// Heading returns any heading override for a photo
+func (m *Manifest) Heading(file string) *float64 {
+	if photo := m.Lookup(file); photo != nil {
+		return photo.Heading
+	}
+	return nil
+}
+
+// Edges returns the manifest connections, or nil if photos should be
+// connected in order
+func (m *Manifest) Edges() [][2]string {
+	if m == nil {
+		return nil
+	}
+	var edges [][2]string
+	for _, connection := range m.Connections {
+		edges = append(edges, [2]string{connection.From, connection.To})
+	}
+	return edges
+}
+
+// Locate is the same as metadata.Locate, but uses any location override in
+// the manifest
+func (m *Manifest) Locate(file string, gpxFilename string, hasTracks bool) (time.Time, float64, float64, float64, string, error) {
+	photo := m.Lookup(file)
+	if photo == nil || photo.Latitude == nil {
+		return metadata.Locate(file, gpxFilename, hasTracks)
+	}
+
+	timestamp, _, _, altitude, _ := metadata.Get(file)
+	if photo.Altitude != nil {
+		altitude = *photo.Altitude
+	}
+	return timestamp, *photo.Latitude, *photo.Longitude, altitude, "manifest", nil
+}
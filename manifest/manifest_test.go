@@ -0,0 +1,67 @@
+package manifest
+
+import (
+	"path"
+	"testing"
+)
+
+func TestManifestNoSuchFile(t *testing.T) {
+	_, err := Load("junk.yaml")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestManifestJunkFile(t *testing.T) {
+	_, err := Load("../testdata/junk.gpx")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestManifestBadConnection(t *testing.T) {
+	_, err := Load("../testdata/badtour.yaml")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestManifestGood(t *testing.T) {
+	tour, err := Load("../testdata/tour.yaml")
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+
+	files := tour.Files()
+	if len(files) != 4 || files[0] != path.Join("../testdata", "3601.jpg") || files[3] != path.Join("../testdata", "good1.gpx") {
+		t.Errorf("unexpected files %v", files)
+	}
+	if tour.PlaceIdFor("../testdata/3601.jpg", "") != "place-1" {
+		t.Errorf("unexpected photo place %s", tour.PlaceIdFor("../testdata/3601.jpg", ""))
+	}
+	if tour.PlaceIdFor("../testdata/flat1.jpg", "") != "place-default" {
+		t.Errorf("unexpected default place %s", tour.PlaceIdFor("../testdata/flat1.jpg", ""))
+	}
+	if tour.PlaceIdFor("../testdata/flat1.jpg", "place-flag") != "place-flag" {
+		t.Errorf("unexpected flag place %s", tour.PlaceIdFor("../testdata/flat1.jpg", "place-flag"))
+	}
+
+	_, lat, long, _, source, err := tour.Locate("../testdata/nolocation.jpg", "", false)
+	if err != nil || lat != 51.5 || long != -0.8 || source != "manifest" {
+		t.Errorf("unexpected location %f, %f from %s - %v", lat, long, source, err)
+	}
+}
+
+func TestManifestNil(t *testing.T) {
+	var tour *Manifest
+	if tour.PlaceIdFor("../testdata/3601.jpg", "place") != "place" {
+		t.Errorf("unexpected place")
+	}
+	if tour.Heading("../testdata/3601.jpg") != nil || tour.Edges() != nil {
+		t.Errorf("unexpected heading or edges")
+	}
+	_, _, _, _, source, err := tour.Locate("../testdata/3601.jpg", "", false)
+	if err != nil || source != "exif" {
+		t.Errorf("unexpected location from %s - %v", source, err)
+	}
+}
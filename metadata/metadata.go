@@ -0,0 +1,224 @@
+// Package metadata reads the capture time, location and projection of
+// photos from their exif and xmp data.
+package metadata
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evanoberholster/imagemeta"
+	"github.com/evanoberholster/imagemeta/jpeg"
+	"github.com/plord12/360tools/track"
+)
+
+// photo file extensions, compared case insensitively.  Insta360 .insp files
+// are jpegs
+var photoExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".insp": true}
+
+// IsPhoto returns true if the file is a supported photo, by extension
+func IsPhoto(file string) bool {
+	return photoExtensions[strings.ToLower(filepath.Ext(file))]
+}
+
+// Get returns the timestamp, latitude, longitude and altitude from the exif
+// data of a photo.  It is an error if the photo has no GPS data, in which
+// case the timestamp is still returned
+func Get(file string) (time.Time, float64, float64, float64, error) {
+	jpg, err := os.Open(file)
+	if err != nil {
+		return time.Time{}, 0.0, 0.0, 0.0, err
+	}
+	defer jpg.Close()
+
+	metadata, err := imagemeta.Decode(jpg)
+	if err != nil {
+		return time.Time{}, 0.0, 0.0, 0.0, err
+	}
+
+	timestamp := metadata.DateTimeOriginal()
+	lat := metadata.GPS.Latitude()
+	long := metadata.GPS.Longitude()
+	altitude := float64(metadata.GPS.Altitude())
+
+	if lat == 0.0 && long == 0.0 && altitude == 0 {
+		return timestamp, 0.0, 0.0, 0.0, errors.New("no GPS data")
+	}
+
+	return timestamp, lat, long, altitude, nil
+}
+
+// Locate returns the timestamp, latitude, longitude and altitude of a
+// photo, falling back to the gpx tracks if the photo has no location.  Also
+// returns where the location came from - exif or gpx
+func Locate(file string, gpxFilename string, hasTracks bool) (time.Time, float64, float64, float64, string, error) {
+	timestamp, lat, long, altitude, err := Get(file)
+	if err == nil && lat == lat && long == long {
+		return timestamp, lat, long, altitude, "exif", nil
+	}
+	if !hasTracks {
+		return timestamp, 0.0, 0.0, 0.0, "", fmt.Errorf("unable to get metadata: %v", err)
+	}
+	lat, long, altitude, err = track.Position(timestamp, gpxFilename)
+	if err != nil {
+		return timestamp, 0.0, 0.0, 0.0, "", fmt.Errorf("unable to get metadata from gpx: %v", err)
+	}
+	return timestamp, lat, long, altitude, "gpx", nil
+}
+
+// Orientation returns the pitch and roll of a photo in degrees, from the
+// GPano PosePitchDegrees and PoseRollDegrees xmp values.  They are zero if
+// the photo doesn't have them
+func Orientation(file string) (float64, float64, error) {
+	jpg, err := os.Open(file)
+	if err != nil {
+		return 0.0, 0.0, err
+	}
+	defer jpg.Close()
+
+	var pitch, roll float64
+	var xmpErr error
+	jpeg.ScanJPEG(jpg, nil, func(r io.Reader) error {
+		pitch, roll, xmpErr = readOrientation(r)
+		return xmpErr
+	})
+	return pitch, roll, xmpErr
+}
+
+// readOrientation reads the pitch and roll from xmp, which may be either
+// elements or attributes of the description
+func readOrientation(r io.Reader) (float64, float64, error) {
+	values := map[string]float64{}
+	set := func(name string, value string) error {
+		if name != "PosePitchDegrees" && name != "PoseRollDegrees" {
+			return nil
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+		values[name] = v
+		return nil
+	}
+
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if tok == nil || err == io.EOF {
+			break
+		} else if err != nil {
+			return 0.0, 0.0, err
+		}
+		if ty, ok := tok.(xml.StartElement); ok {
+			for _, attr := range ty.Attr {
+				err = set(attr.Name.Local, attr.Value)
+				if err != nil {
+					return 0.0, 0.0, err
+				}
+			}
+			if ty.Name.Local == "PosePitchDegrees" || ty.Name.Local == "PoseRollDegrees" {
+				var value data
+				err = d.DecodeElement(&value, &ty)
+				if err == nil {
+					err = set(ty.Name.Local, value.Data)
+				}
+				if err != nil {
+					return 0.0, 0.0, err
+				}
+			}
+		}
+	}
+	return values["PosePitchDegrees"], values["PoseRollDegrees"], nil
+}
+
+type data struct {
+	Data string `xml:",chardata"`
+}
+
+type panoData struct {
+	Data string `xml:"ProjectionType,attr"`
+}
+
+// Is360 returns true if the xmp data of a photo says it has an
+// equirectangular projection
+func Is360(file string) bool {
+
+	equirectangular := false
+
+	// read xmp data to check if this is a 360 image
+	//
+
+	reader := func(r io.Reader) error {
+		d := xml.NewDecoder(r)
+		for {
+			tok, err := d.Token()
+			if tok == nil || err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			switch ty := tok.(type) {
+			case xml.StartElement:
+				if ty.Name.Local == "ProjectionType" {
+					var projectionType data
+					err = d.DecodeElement(&projectionType, &ty)
+					if err != nil {
+						return err
+					}
+					if projectionType.Data == "equirectangular" {
+						equirectangular = true
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	panoReader := func(r io.Reader) error {
+		d := xml.NewDecoder(r)
+		for {
+			tok, err := d.Token()
+			if tok == nil || err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			switch ty := tok.(type) {
+			case xml.StartElement:
+				if ty.Name.Local == "Description" {
+					var projectionType panoData
+					err = d.DecodeElement(&projectionType, &ty)
+					if err != nil {
+						return err
+					}
+					if projectionType.Data == "equirectangular" {
+						equirectangular = true
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	jpg, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	jpeg.ScanJPEG(jpg, nil, reader)
+	jpg.Close()
+
+	jpg, err = os.Open(file)
+	if err != nil {
+		return false
+	}
+	jpeg.ScanJPEG(jpg, nil, panoReader)
+	jpg.Close()
+
+	return equirectangular
+}
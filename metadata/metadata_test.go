@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsPhoto(t *testing.T) {
+	for _, file := range []string{"a.jpg", "a.JPG", "a.jpeg", "a.JPEG", "a.Jpg", "a.insp", "a.INSP"} {
+		if !IsPhoto(file) {
+			t.Errorf("%s not a photo", file)
+		}
+	}
+	if IsPhoto("a.gpx") || IsPhoto("a.png") {
+		t.Errorf("unexpected photo")
+	}
+}
+
+func TestIs360(t *testing.T) {
+	if !Is360("../testdata/3601.jpg") || Is360("../testdata/flat1.jpg") || Is360("junk.jpg") {
+		t.Errorf("unexpected projection")
+	}
+}
+
+func TestLocate(t *testing.T) {
+	_, _, _, _, source, err := Locate("../testdata/3601.jpg", "", false)
+	if err != nil || source != "exif" {
+		t.Errorf("unexpected location from %s - %v", source, err)
+	}
+	_, _, _, _, _, err = Locate("../testdata/nolocation.jpg", "", false)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+	_, _, _, _, source, err = Locate("../testdata/nolocation.jpg", "../testdata/good1.gpx", true)
+	if err != nil || source != "gpx" {
+		t.Errorf("unexpected location from %s - %v", source, err)
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	pitch, roll, err := Orientation("../testdata/3601.jpg")
+	if err != nil || pitch != 0 || roll != 0 {
+		t.Errorf("unexpected orientation %f, %f - %v", pitch, roll, err)
+	}
+	_, _, err = Orientation("junk.jpg")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestReadOrientation(t *testing.T) {
+	elements := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:GPano="http://ns.google.com/photos/1.0/panorama/">
+<GPano:PoseHeadingDegrees>90.0</GPano:PoseHeadingDegrees><GPano:PosePitchDegrees>1.5</GPano:PosePitchDegrees><GPano:PoseRollDegrees>-2.25</GPano:PoseRollDegrees>
+</rdf:Description></rdf:RDF></x:xmpmeta>`
+	pitch, roll, err := readOrientation(strings.NewReader(elements))
+	if err != nil || pitch != 1.5 || roll != -2.25 {
+		t.Errorf("unexpected orientation %f, %f - %v", pitch, roll, err)
+	}
+
+	attributes := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description xmlns:GPano="http://ns.google.com/photos/1.0/panorama/"
+GPano:PosePitchDegrees="-3" GPano:PoseRollDegrees="4"/></rdf:RDF></x:xmpmeta>`
+	pitch, roll, err = readOrientation(strings.NewReader(attributes))
+	if err != nil || pitch != -3 || roll != 4 {
+		t.Errorf("unexpected orientation %f, %f - %v", pitch, roll, err)
+	}
+
+	_, _, err = readOrientation(strings.NewReader(`<GPano:PoseRollDegrees>level</GPano:PoseRollDegrees>`))
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
@@ -0,0 +1,292 @@
+// google oauth functions
+//
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plord12/360tools/quota"
+	"github.com/plord12/360tools/streetview"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+// startOauth returns a Street View client authorized as the user, asking
+// them to log in if there is no cached token
+func startOauth(creds *googleFlags) (*streetview.Client, error) {
+	config, err := oauthConfig(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	client, err := newOAuthClient(creds, ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return streetview.NewClient(ctx, quota.Wrap(client, *creds.rate))
+}
+
+// oauthConfig returns the client configuration from the client JSON file
+// the Cloud console downloads, if there is one and no client id or secret
+// is given, otherwise from the client id and secret
+func oauthConfig(creds *googleFlags) (*oauth2.Config, error) {
+	if *creds.clientID == "" && *creds.secret == "" && *creds.clientSecret != "" {
+		data, err := os.ReadFile(*creds.clientSecret)
+		if err == nil {
+			config, err := google.ConfigFromJSON(data, streetviewpublish.StreetviewpublishScope)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client file %s: %v", *creds.clientSecret, err)
+			}
+			return config, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	clientID, err := valueOrFile(*creds.clientID, *creds.clientIDFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client id: %v", err)
+	}
+	secret, err := valueOrFile(*creds.secret, *creds.secretFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client secret: %v", err)
+	}
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: secret,
+		Endpoint:     google.Endpoint,
+		Scopes:       []string{streetviewpublish.StreetviewpublishScope},
+	}, nil
+}
+
+// errNeedsAuthorization is returned when there is no cached token, or it
+// can't be refreshed, so the user has to authorize again
+var errNeedsAuthorization = errors.New("authorization needed")
+
+// savingTokenSource gets tokens from source, refreshing them as needed, and
+// saves each new one ( including any rotated refresh token ) to the cache
+type savingTokenSource struct {
+	mu     sync.Mutex
+	source oauth2.TokenSource
+	store  *tokenStore
+	last   *oauth2.Token
+}
+
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil || token.AccessToken != s.last.AccessToken || token.RefreshToken != s.last.RefreshToken {
+		s.store.saveOrWarn(token)
+		s.last = token
+	}
+	return token, nil
+}
+
+func newSavingTokenSource(ctx context.Context, config *oauth2.Config, token *oauth2.Token, store *tokenStore) *savingTokenSource {
+	return &savingTokenSource{source: config.TokenSource(ctx, token), store: store, last: token}
+}
+
+// cachedTokenSource returns a token source starting from the cached token,
+// refreshing it now if it has expired.  Returns errNeedsAuthorization if
+// there is no cached token or Google refuses to refresh it, for example
+// because it was revoked.  A nil store caches nothing
+func cachedTokenSource(ctx context.Context, config *oauth2.Config, store *tokenStore) (oauth2.TokenSource, error) {
+	if store == nil {
+		return nil, errNeedsAuthorization
+	}
+	token, err := store.load()
+	if os.IsNotExist(err) {
+		return nil, errNeedsAuthorization
+	}
+	if err != nil {
+		return nil, err
+	}
+	source := newSavingTokenSource(ctx, config, token, store)
+	_, err = source.Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if token.RefreshToken == "" || errors.As(err, &retrieveErr) {
+			log.Printf("Cached token can't be refreshed: %v", err)
+			return nil, errNeedsAuthorization
+		}
+		return nil, fmt.Errorf("unable to refresh token: %v", err)
+	}
+	log.Printf("Using cached token")
+	return source, nil
+}
+
+// newOAuthClient returns a client authorized with the cached token, only
+// asking the user to authorize in a browser if it can't be refreshed.
+// Refreshed tokens are saved for next time
+func newOAuthClient(creds *googleFlags, ctx context.Context, config *oauth2.Config) (*http.Client, error) {
+	var store *tokenStore
+	if *creds.cacheToken {
+		file, err := tokenCacheFile(config)
+		if err != nil {
+			log.Printf("Warning: unable to cache oauth token: %v", err)
+		} else {
+			passphrase, err := valueOrFile(*creds.passphrase, *creds.passphraseFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read token passphrase: %v", err)
+			}
+			store = &tokenStore{file: file, passphrase: passphrase, legacy: legacyTokenFile(config)}
+		}
+	}
+
+	source, err := cachedTokenSource(ctx, config, store)
+	if err == errNeedsAuthorization {
+		token, err := tokenFromWeb(ctx, config, *creds.authMode, *creds.authPort, os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		store.saveOrWarn(token)
+		return oauth2.NewClient(ctx, newSavingTokenSource(ctx, config, token, store)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, source), nil
+}
+
+// authorization modes, see --auth-mode
+const (
+	authBrowser  = "browser"
+	authLoopback = "loopback"
+	authManual   = "manual"
+)
+
+var authModes = []string{authBrowser, authLoopback, authManual}
+
+// tokenFromWeb asks the user to authorize access in a browser.  Google
+// redirects the browser to 127.0.0.1 with a code, which is either picked up
+// by a local server ( browser and loopback ) or pasted in ( manual, read
+// from in ) - then exchanged for a token
+func tokenFromWeb(ctx context.Context, config *oauth2.Config, mode string, port int, in io.Reader) (*oauth2.Token, error) {
+	randState := fmt.Sprintf("st%d", time.Now().UnixNano())
+
+	var code string
+	var err error
+	if mode == authManual {
+		code, err = codeFromPaste(config, randState, port, in)
+	} else {
+		code, err = codeFromLoopback(config, randState, port, mode == authBrowser)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Got code: %s", code)
+
+	token, err := config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange error: %v", err)
+	}
+	return token, nil
+}
+
+// codeFromLoopback waits for the browser to be redirected to a local
+// server, optionally opening the browser first
+func codeFromLoopback(config *oauth2.Config, randState string, port int, open bool) (string, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return "", fmt.Errorf("unable to listen for the authorization redirect: %v", err)
+	}
+
+	ch := make(chan string, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/favicon.ico" {
+			http.Error(rw, "", 404)
+			return
+		}
+		if req.FormValue("state") != randState {
+			log.Printf("State doesn't match: req = %#v", req)
+			http.Error(rw, "", 500)
+			return
+		}
+		if code := req.FormValue("code"); code != "" {
+			fmt.Fprintf(rw, "<h1>Success</h1>Authorized.")
+			rw.(http.Flusher).Flush()
+			select {
+			case ch <- code:
+			default:
+			}
+			return
+		}
+		log.Printf("no code")
+		http.Error(rw, "", 500)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	config.RedirectURL = "http://" + listener.Addr().String()
+	authURL := config.AuthCodeURL(randState)
+	if open {
+		go openURL(authURL)
+	}
+	log.Printf("Authorize this app at: %s", authURL)
+	return <-ch, nil
+}
+
+// codeFromPaste asks for the address the browser was redirected to, or just
+// the code in it, to be pasted in
+func codeFromPaste(config *oauth2.Config, randState string, port int, in io.Reader) (string, error) {
+	config.RedirectURL = "http://127.0.0.1"
+	if port != 0 {
+		config.RedirectURL = fmt.Sprintf("http://127.0.0.1:%d", port)
+	}
+	authURL := config.AuthCodeURL(randState)
+	log.Printf("Authorize this app at: %s", authURL)
+	fmt.Fprintf(os.Stderr, "Then paste the address your browser is sent to ( the page won't load ), or just the code in it: ")
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	pasted := strings.TrimSpace(line)
+	if pasted == "" {
+		if err == nil {
+			err = errors.New("nothing pasted")
+		}
+		return "", fmt.Errorf("unable to read authorization code: %v", err)
+	}
+	return pastedCode(pasted, randState)
+}
+
+// pastedCode returns the code from a pasted redirect address, or the code
+// itself
+func pastedCode(pasted string, randState string) (string, error) {
+	if !strings.Contains(pasted, "code=") {
+		return pasted, nil
+	}
+	redirect, err := url.Parse(pasted)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %s: %v", pasted, err)
+	}
+	query := redirect.Query()
+	if state := query.Get("state"); state != "" && state != randState {
+		return "", errors.New("state doesn't match - paste the address from this authorization")
+	}
+	if errorCode := query.Get("error"); errorCode != "" {
+		return "", fmt.Errorf("authorization failed: %s", errorCode)
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", errors.New("no code in address")
+	}
+	return code, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newTokenServer refreshes tokens with a new access token each time, or
+// refuses if revoked
+func newTokenServer(revoked *bool, refreshes *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		if *revoked {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(`{"error": "invalid_grant", "error_description": "Token has been expired or revoked."}`))
+			return
+		}
+		*refreshes++
+		rw.Write([]byte(`{"access_token": "access-` + strconv.Itoa(*refreshes) + `", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+}
+
+func TestCachedTokenSource(t *testing.T) {
+	revoked, refreshes := false, 0
+	ts := newTokenServer(&revoked, &refreshes)
+	defer ts.Close()
+	config := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: ts.URL}}
+
+	dir, _ := os.MkdirTemp("", "token")
+	defer os.RemoveAll(dir)
+	store := &tokenStore{file: filepath.Join(dir, "token.json")}
+
+	// no cached token
+	//
+	_, err := cachedTokenSource(context.Background(), config, store)
+	if err != errNeedsAuthorization {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// an expired token is refreshed, keeping the refresh token, and saved
+	//
+	store.save(&oauth2.Token{AccessToken: "access-0", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)})
+	source, err := cachedTokenSource(context.Background(), config, store)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	token, _ := source.Token()
+	saved, _ := store.load()
+	if refreshes != 1 || token.AccessToken != "access-1" || saved.AccessToken != "access-1" || saved.RefreshToken != "refresh" {
+		t.Errorf("unexpected token %v, saved %v after %d refreshes", token, saved, refreshes)
+	}
+
+	// a valid token isn't refreshed
+	//
+	_, err = cachedTokenSource(context.Background(), config, store)
+	if err != nil || refreshes != 1 {
+		t.Errorf("unexpected refresh %v", err)
+	}
+
+	// a revoked token needs authorizing again
+	//
+	revoked = true
+	store.save(&oauth2.Token{AccessToken: "access-0", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)})
+	_, err = cachedTokenSource(context.Background(), config, store)
+	if err != errNeedsAuthorization {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestPastedCode(t *testing.T) {
+	for pasted, want := range map[string]string{
+		"4/abc": "4/abc",
+		"http://127.0.0.1/?state=st1&code=4/abc&scope=x": "4/abc",
+		"http://127.0.0.1:8085/?code=4%2Fabc":            "4/abc",
+	} {
+		code, err := pastedCode(pasted, "st1")
+		if err != nil || code != want {
+			t.Errorf("%s: unexpected code %s %v", pasted, code, err)
+		}
+	}
+	for _, pasted := range []string{"http://127.0.0.1/?state=st2&code=4/abc", "http://127.0.0.1/?state=st1&error=access_denied&code="} {
+		_, err := pastedCode(pasted, "st1")
+		if err == nil {
+			t.Errorf("%s: didn't fail", pasted)
+		}
+	}
+}
+
+func TestCodeFromPaste(t *testing.T) {
+	config := &oauth2.Config{ClientID: "id", Endpoint: oauth2.Endpoint{AuthURL: "http://auth"}}
+	code, err := codeFromPaste(config, "st1", 8085, strings.NewReader("  http://127.0.0.1:8085/?state=st1&code=abc\n"))
+	if err != nil || code != "abc" {
+		t.Errorf("unexpected code %s %v", code, err)
+	}
+	if config.RedirectURL != "http://127.0.0.1:8085" {
+		t.Errorf("unexpected redirect %s", config.RedirectURL)
+	}
+
+	_, err = codeFromPaste(config, "st1", 0, strings.NewReader(""))
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestCodeFromLoopback(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	// the browser being redirected
+	//
+	go func() {
+		for i := 0; i < 100; i++ {
+			resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/?state=st1&code=abc", port))
+			if err == nil {
+				resp.Body.Close()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	config := &oauth2.Config{ClientID: "id", Endpoint: oauth2.Endpoint{AuthURL: "http://auth"}}
+	code, err := codeFromLoopback(config, "st1", port, false)
+	if err != nil || code != "abc" {
+		t.Errorf("unexpected code %s %v", code, err)
+	}
+	if config.RedirectURL != fmt.Sprintf("http://127.0.0.1:%d", port) {
+		t.Errorf("unexpected redirect %s", config.RedirectURL)
+	}
+}
+
+func TestOauthConfigMissing(t *testing.T) {
+	dir := t.TempDir()
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	creds := addGoogleFlags(fs)
+	fs.Parse([]string{"--client-secret-file", filepath.Join(dir, "client_secret.json"), "--clientid-file", filepath.Join(dir, "clientid.dat")})
+
+	// a missing client id file is an error, rather than exiting
+	//
+	_, err := oauthConfig(creds)
+	if err == nil || !strings.Contains(err.Error(), "client id") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
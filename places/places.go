@@ -0,0 +1,397 @@
+// Package places finds Google places near photos with the Places API.
+//
+// See https://developers.google.com/maps/documentation/places/web-service/search-nearby
+package places
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/plord12/360tools/geo"
+	"github.com/plord12/360tools/manifest"
+	"github.com/plord12/360tools/metadata"
+	"github.com/plord12/360tools/report"
+)
+
+// nearbySearchURL is the Places API nearby search endpoint
+var nearbySearchURL = "https://maps.googleapis.com/maps/api/place/nearbysearch/json"
+
+// how long to wait before using a next_page_token, and how many times to
+// try it
+var (
+	pageTokenDelay    = 2 * time.Second
+	pageTokenAttempts = 3
+)
+
+type location struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+type result struct {
+	Name     string   `json:"name"`
+	PlaceId  string   `json:"place_id"`
+	Vicinity string   `json:"vicinity"`
+	Types    []string `json:"types"`
+	Geometry struct {
+		Location location `json:"location"`
+	} `json:"geometry"`
+}
+
+type response struct {
+	Results       []result `json:"results"`
+	NextPageToken string   `json:"next_page_token"`
+	Status        string   `json:"status"`
+	ErrorMessage  string   `json:"error_message"`
+}
+
+// Place is a Google place
+type Place struct {
+	PlaceId   string
+	Name      string
+	Vicinity  string
+	Latitude  float64
+	Longitude float64
+}
+
+// nearbySearch makes one Places nearby search, returning an error for any
+// status but OK and ZERO_RESULTS.  Errors never include the api key
+func nearbySearch(client *http.Client, params url.Values) (*response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpresp, err := client.Get(nearbySearchURL + "?" + params.Encode())
+	if err != nil {
+		// the error includes the url, so drop the query and its api key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, &url.Error{Op: urlErr.Op, URL: nearbySearchURL, Err: urlErr.Err}
+		}
+		return nil, err
+	}
+	defer httpresp.Body.Close()
+	body, err := io.ReadAll(httpresp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &response{}
+	err = json.Unmarshal(body, response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid places response ( status %s ): %v", httpresp.Status, err)
+	}
+	if httpresp.StatusCode != http.StatusOK && response.Status == "" {
+		response.Status = httpresp.Status
+	}
+	if response.Status != "OK" && response.Status != "ZERO_RESULTS" {
+		return nil, &statusError{Status: response.Status, Message: response.ErrorMessage}
+	}
+	return response, nil
+}
+
+// statusError is a search Google refused, such as REQUEST_DENIED for an
+// invalid key
+type statusError struct {
+	Status  string
+	Message string
+}
+
+func (e *statusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("places search failed - %s: %s", e.Status, e.Message)
+	}
+	return "places search failed - " + e.Status
+}
+
+// Finder finds the nearest place to a location
+type Finder struct {
+	// Client makes the requests, http.DefaultClient if nil
+	Client *http.Client
+	APIKey string
+	// Radius is the furthest, in metres, a place can be
+	Radius float64
+	// Type, if not empty, is the type of place such as store or cafe
+	Type string
+}
+
+// Nearest returns the nearest place within the radius, and how far away it
+// is, or nil if there are none.  A search within the radius ranks places by
+// prominence, so with a type places are ranked by distance instead - the
+// nearest is then on the first page.  Without a type ( which ranking by
+// distance needs ) every page within the radius is searched
+func (f *Finder) Nearest(latitude float64, longitude float64) (*Place, float64, error) {
+	var results []result
+	if f.Type != "" {
+		params := url.Values{}
+		params.Set("location", fmt.Sprintf("%f,%f", latitude, longitude))
+		params.Set("rankby", "distance")
+		params.Set("type", f.Type)
+		params.Set("key", f.APIKey)
+		response, err := nearbySearch(f.Client, params)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = response.Results
+	} else {
+		var err error
+		results, err = searchAll(f.Client, f.APIKey, Search{Radius: f.Radius}, latitude, longitude)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var nearest *Place
+	nearestDistance := 0.0
+	for _, result := range results {
+		x, y := geo.Displacement(latitude, longitude, result.Geometry.Location.Lat, result.Geometry.Location.Lng)
+		distance := math.Hypot(x, y)
+		if distance > f.Radius || (nearest != nil && distance >= nearestDistance) {
+			continue
+		}
+		nearest = &Place{PlaceId: result.PlaceId, Name: result.Name, Vicinity: result.Vicinity, Latitude: result.Geometry.Location.Lat, Longitude: result.Geometry.Location.Lng}
+		nearestDistance = distance
+	}
+	return nearest, nearestDistance, nil
+}
+
+// LoadAssignments reads the place for each photo from a csv file of photo
+// file and place id, with an optional header.  Returned by absolute file
+// name, resolving names relative to the csv file
+func LoadAssignments(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	assignments := map[string]string{}
+	for i, record := range records {
+		if i == 0 && len(record) >= 2 && strings.EqualFold(strings.TrimSpace(record[0]), "file") {
+			continue
+		}
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" || strings.TrimSpace(record[1]) == "" {
+			return nil, fmt.Errorf("%s: line %d needs a file and place id", filename, i+1)
+		}
+		file := strings.TrimSpace(record[0])
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(filename), file)
+		}
+		file, err = filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		assignments[file] = strings.TrimSpace(record[1])
+	}
+	if len(assignments) == 0 {
+		return nil, errors.New(filename + ": no places")
+	}
+	return assignments, nil
+}
+
+// Search is what to look for near each photo
+type Search struct {
+	// Radius is the furthest, in metres, to search - if zero places are
+	// ranked by distance instead, needing a keyword or type
+	Radius float64
+	// Keyword, if not empty, is matched against everything Google has
+	// about the place
+	Keyword string
+	// Type, if not empty, is the type of place such as store or cafe
+	Type string
+}
+
+// Poi is a point of interest near the photos
+type Poi struct {
+	PlaceId   string   `json:"placeId"`
+	Name      string   `json:"name"`
+	Address   string   `json:"address"`
+	Types     []string `json:"types"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	// Distance, in metres, and Bearing, in degrees, are from the centroid
+	// of the photos
+	Distance float64 `json:"distance"`
+	Bearing  float64 `json:"bearing"`
+	// Photos are the photos the place was found near
+	Photos []string `json:"photos"`
+}
+
+// ListPois returns the points of interest near the photos, nearest the
+// centroid of the photos first, recording those near each photo in the
+// report.  Searches are made with client, or http.DefaultClient if nil,
+// following every page of results.  Photos without a location, or that the
+// search fails for, are skipped unless strict when the first one is an
+// error.  A refused request, such as for an invalid key, stops the search
+func ListPois(client *http.Client, apiKey string, search Search, imageFilenames []string, runReport *report.Report, tour *manifest.Manifest, strict bool) ([]*Poi, error) {
+
+	var pois []*Poi
+	found := make(map[string]*Poi)
+	var latSum, lonSum float64
+	located := 0
+
+	for _, imageFilename := range imageFilenames {
+
+		if !metadata.IsPhoto(imageFilename) {
+			continue
+		}
+
+		entry := &report.Entry{File: imageFilename, Outcome: report.Found}
+		runReport.Add(entry)
+
+		timestamp, lat, long, altitude, source, err := tour.Locate(imageFilename, "", false)
+		if err != nil {
+			// ignore for this file, just see less places
+			entry.SetError(report.Skipped, err)
+			if strict {
+				return nil, fmt.Errorf("%s: %v", imageFilename, err)
+			}
+			continue
+		}
+		entry.SetLocation(timestamp, lat, long, altitude, source)
+		latSum += lat
+		lonSum += long
+		located++
+
+		results, err := searchAll(client, apiKey, search, lat, long)
+		if err != nil {
+			entry.SetError(report.Failed, err)
+			var refused *statusError
+			if strict || (errors.As(err, &refused) && refused.Status == "REQUEST_DENIED") {
+				return nil, fmt.Errorf("%s: %v", imageFilename, err)
+			}
+			log.Printf("%s: %v", imageFilename, err)
+			continue
+		}
+
+		for _, result := range results {
+			entry.Places = append(entry.Places, report.Place{PlaceId: result.PlaceId, Name: result.Name})
+			poi, exists := found[result.PlaceId]
+			if !exists {
+				poi = &Poi{PlaceId: result.PlaceId, Name: result.Name, Address: result.Vicinity, Types: result.Types,
+					Latitude: result.Geometry.Location.Lat, Longitude: result.Geometry.Location.Lng}
+				found[result.PlaceId] = poi
+				pois = append(pois, poi)
+			}
+			poi.Photos = append(poi.Photos, imageFilename)
+		}
+	}
+
+	if located > 0 {
+		centroidLat, centroidLon := latSum/float64(located), lonSum/float64(located)
+		for _, poi := range pois {
+			x, y := geo.Displacement(centroidLat, centroidLon, poi.Latitude, poi.Longitude)
+			poi.Distance = math.Hypot(x, y)
+			poi.Bearing = geo.Bearing(centroidLat, centroidLon, poi.Latitude, poi.Longitude)
+		}
+		sort.SliceStable(pois, func(a, b int) bool {
+			return pois[a].Distance < pois[b].Distance
+		})
+	}
+	return pois, nil
+}
+
+// searchAll returns every page of places near a location
+func searchAll(client *http.Client, apiKey string, search Search, latitude float64, longitude float64) ([]result, error) {
+	params := url.Values{}
+	params.Set("location", fmt.Sprintf("%f,%f", latitude, longitude))
+	params.Set("key", apiKey)
+	if search.Radius > 0 {
+		params.Set("radius", strconv.FormatFloat(search.Radius, 'f', -1, 64))
+	} else {
+		params.Set("rankby", "distance")
+	}
+	if search.Keyword != "" {
+		params.Set("keyword", search.Keyword)
+	}
+	if search.Type != "" {
+		params.Set("type", search.Type)
+	}
+
+	response, err := nearbySearch(client, params)
+	if err != nil {
+		return nil, err
+	}
+	results := response.Results
+	for response.NextPageToken != "" {
+		response, err = nextPage(client, apiKey, response.NextPageToken)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, response.Results...)
+	}
+	return results, nil
+}
+
+// nextPage returns the page of results for a next_page_token.  The token
+// only becomes valid a short time after it is issued, until then the
+// search is an INVALID_REQUEST - so wait, and retry a few times
+func nextPage(client *http.Client, apiKey string, token string) (*response, error) {
+	params := url.Values{}
+	params.Set("pagetoken", token)
+	params.Set("key", apiKey)
+	for attempt := 1; ; attempt++ {
+		time.Sleep(pageTokenDelay)
+		response, err := nearbySearch(client, params)
+		var invalid *statusError
+		if err == nil || !errors.As(err, &invalid) || invalid.Status != "INVALID_REQUEST" || attempt >= pageTokenAttempts {
+			return response, err
+		}
+	}
+}
+
+// PrintPois writes points of interest as a table, csv ( format csv ) or
+// json ( format json )
+func PrintPois(w io.Writer, pois []*Poi, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "PLACE ID\tNAME\tADDRESS\tDISTANCE\tBEARING\n")
+		for _, poi := range pois {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.0fm\t%.0f\n", poi.PlaceId, poi.Name, poi.Address, poi.Distance, poi.Bearing)
+		}
+		return tw.Flush()
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"placeId", "name", "address", "types", "latitude", "longitude", "distance", "bearing", "photos"})
+		for _, poi := range pois {
+			cw.Write([]string{poi.PlaceId, poi.Name, poi.Address, strings.Join(poi.Types, "; "),
+				strconv.FormatFloat(poi.Latitude, 'f', -1, 64), strconv.FormatFloat(poi.Longitude, 'f', -1, 64),
+				strconv.FormatFloat(poi.Distance, 'f', 1, 64), strconv.FormatFloat(poi.Bearing, 'f', 1, 64),
+				strings.Join(poi.Photos, "; ")})
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "json":
+		if pois == nil {
+			pois = []*Poi{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(pois)
+	}
+	return fmt.Errorf("invalid format %s", format)
+}
@@ -0,0 +1,197 @@
+package places
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plord12/360tools/report"
+)
+
+// testPlaces serves body as the nearby search response, returning the last
+// query made
+func testPlaces(t *testing.T, body string) *string {
+	t.Helper()
+	var last string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r.URL.RawQuery
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	saved := nearbySearchURL
+	nearbySearchURL = server.URL
+	t.Cleanup(func() { nearbySearchURL = saved })
+	return &last
+}
+
+const twoPlaces = `{"status": "OK", "results": [
+	{"name": "Far", "place_id": "far", "vicinity": "2 High Street", "geometry": {"location": {"lat": 51.0003, "lng": 0.0}}},
+	{"name": "Near", "place_id": "near", "vicinity": "1 High Street", "geometry": {"location": {"lat": 51.0001, "lng": 0.0}}}
+]}`
+
+func TestNearest(t *testing.T) {
+	last := testPlaces(t, twoPlaces)
+
+	finder := &Finder{APIKey: "key", Radius: 50, Type: "store"}
+	place, distance, err := finder.Nearest(51, 0)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	if place == nil || place.PlaceId != "near" || place.Vicinity != "1 High Street" {
+		t.Fatalf("unexpected place %v", place)
+	}
+	if distance < 10 || distance > 12 {
+		t.Errorf("unexpected distance %f", distance)
+	}
+	if *last != "key=key&location=51.000000%2C0.000000&rankby=distance&type=store" {
+		t.Errorf("unexpected query %s", *last)
+	}
+
+	// nothing within the radius
+	//
+	finder.Radius = 5
+	place, _, err = finder.Nearest(51, 0)
+	if err != nil || place != nil {
+		t.Errorf("unexpected place %v %v", place, err)
+	}
+	// any type can't be ranked by distance, so is searched within the
+	// radius
+	//
+	finder.Type = ""
+	finder.Radius = 50
+	place, _, err = finder.Nearest(51, 0)
+	if err != nil || place == nil || place.PlaceId != "near" {
+		t.Errorf("unexpected place %v %v", place, err)
+	}
+	if *last != "key=key&location=51.000000%2C0.000000&radius=50" {
+		t.Errorf("unexpected query %s", *last)
+	}
+}
+
+func TestNearestError(t *testing.T) {
+	testPlaces(t, `{"status": "REQUEST_DENIED", "error_message": "The provided API key is invalid.", "results": []}`)
+
+	_, _, err := (&Finder{APIKey: "bad", Radius: 50}).Nearest(51, 0)
+	if err == nil || err.Error() != "places search failed - REQUEST_DENIED: The provided API key is invalid." {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	testPlaces(t, `{"status": "ZERO_RESULTS", "results": []}`)
+	place, _, err := (&Finder{APIKey: "key", Radius: 50}).Nearest(51, 0)
+	if err != nil || place != nil {
+		t.Errorf("unexpected place %v %v", place, err)
+	}
+	// a failed request doesn't give away the key
+	//
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	nearbySearchURL = server.URL
+	_, _, err = (&Finder{APIKey: "secret", Radius: 50}).Nearest(51, 0)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestLoadAssignments(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "places.csv")
+	os.WriteFile(filename, []byte("file,placeId\nshop1.jpg, place1\n# closed\n/photos/shop2.jpg,place2\n"), 0644)
+
+	assignments, err := LoadAssignments(filename)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	if len(assignments) != 2 || assignments[filepath.Join(dir, "shop1.jpg")] != "place1" || assignments["/photos/shop2.jpg"] != "place2" {
+		t.Errorf("unexpected assignments %v", assignments)
+	}
+
+	os.WriteFile(filename, []byte("shop1.jpg\n"), 0644)
+	_, err = LoadAssignments(filename)
+	if err == nil {
+		t.Errorf("missing place id succeeded")
+	}
+
+	_, err = LoadAssignments(filepath.Join(dir, "missing.csv"))
+	if err == nil {
+		t.Errorf("missing file succeeded")
+	}
+}
+
+func TestListPois(t *testing.T) {
+	pageTokenDelay = 0
+	defer func() { pageTokenDelay = 2 * time.Second }()
+
+	var queries []string
+	tokenTries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.FormValue("pagetoken") == "" {
+			fmt.Fprint(w, `{"status": "OK", "next_page_token": "page2", "results": [
+				{"name": "Far", "place_id": "far", "vicinity": "2 High Street", "types": ["store"], "geometry": {"location": {"lat": 51.5, "lng": -0.8}}}]}`)
+			return
+		}
+		// the token isn't valid straight away
+		//
+		tokenTries++
+		if tokenTries == 1 {
+			fmt.Fprint(w, `{"status": "INVALID_REQUEST", "results": []}`)
+			return
+		}
+		fmt.Fprint(w, `{"status": "OK", "results": [
+			{"name": "Near", "place_id": "near", "vicinity": "1 High Street", "geometry": {"location": {"lat": 51.41, "lng": -0.85}}}]}`)
+	}))
+	defer server.Close()
+	saved := nearbySearchURL
+	nearbySearchURL = server.URL
+	defer func() { nearbySearchURL = saved }()
+
+	runReport := report.New("pois")
+	pois, err := ListPois(nil, "key", Search{Radius: 500, Keyword: "shop"}, []string{"../testdata/3601.jpg", "../testdata/nolocation.jpg"}, runReport, nil, false)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	if len(pois) != 2 || pois[0].PlaceId != "near" || pois[1].PlaceId != "far" {
+		t.Fatalf("unexpected pois %v", pois)
+	}
+	if pois[0].Distance <= 0 || pois[0].Distance >= pois[1].Distance || pois[1].Bearing <= 0 || len(pois[1].Photos) != 1 {
+		t.Errorf("unexpected distances %v %v", pois[0], pois[1])
+	}
+	if len(queries) != 3 || queries[0] != "key=key&keyword=shop&location=51.427768%2C-0.853968&radius=500" || queries[2] != "key=key&pagetoken=page2" {
+		t.Errorf("unexpected queries %v", queries)
+	}
+	if len(runReport.Files) != 2 || len(runReport.Files[0].Places) != 2 || runReport.Files[1].Outcome != report.Skipped {
+		t.Errorf("unexpected report %v", runReport.Files)
+	}
+
+	var b bytes.Buffer
+	err = PrintPois(&b, pois, "csv")
+	if err != nil || !strings.HasPrefix(b.String(), "placeId,name,address,types,latitude,longitude,distance,bearing,photos\nnear,Near,1 High Street,") {
+		t.Errorf("unexpected csv %s %v", b.String(), err)
+	}
+	b.Reset()
+	err = PrintPois(&b, pois, "json")
+	var decoded []Poi
+	if err != nil || json.Unmarshal(b.Bytes(), &decoded) != nil || len(decoded) != 2 || decoded[1].Address != "2 High Street" {
+		t.Errorf("unexpected json %s %v", b.String(), err)
+	}
+}
+
+func TestListPoisDenied(t *testing.T) {
+	testPlaces(t, `{"status": "REQUEST_DENIED", "error_message": "The provided API key is invalid.", "results": []}`)
+
+	runReport := report.New("pois")
+	_, err := ListPois(nil, "bad", Search{Type: "cafe"}, []string{"../testdata/3601.jpg", "../testdata/3601.jpg"}, runReport, nil, false)
+	if err == nil || !strings.Contains(err.Error(), "REQUEST_DENIED: The provided API key is invalid.") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(runReport.Files) != 1 || runReport.Files[0].Outcome != report.Failed {
+		t.Errorf("unexpected report %v", runReport.Files)
+	}
+}
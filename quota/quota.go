@@ -0,0 +1,175 @@
+// Package quota wraps http clients for Google APIs so that they stay within
+// request quotas - requests are spaced out to a maximum rate, and requests
+// refused with 429 or failing with 5xx are retried, honouring Retry-After
+// or otherwise backing off exponentially with jitter.
+package quota
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRetries is how many times a request is retried by default
+const DefaultRetries = 5
+
+// backoff starts at retryInterval, doubling up to maxRetryInterval, and
+// Retry-After is capped at maxRetryAfter
+var (
+	retryInterval    = 1 * time.Second
+	maxRetryInterval = 32 * time.Second
+	maxRetryAfter    = 5 * time.Minute
+)
+
+// Transport is an http.RoundTripper that limits the request rate and
+// retries requests that hit a quota or a server error.  Requests with a
+// body that can't be replayed ( no GetBody ), or made with a context from
+// WithoutRetries, aren't retried
+type Transport struct {
+	// Base makes the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+	// Retries is the number of times to retry a request
+	Retries int
+
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+type withoutRetriesKey struct{}
+
+// WithoutRetries returns a context whose requests are rate limited but not
+// retried, for callers that retry themselves - so a failing request isn't
+// retried by both
+func WithoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutRetriesKey{}, true)
+}
+
+// NewTransport returns a Transport making at most requestsPerSecond
+// requests, or any number if zero, with DefaultRetries
+func NewTransport(base http.RoundTripper, requestsPerSecond float64) *Transport {
+	t := &Transport{Base: base, Retries: DefaultRetries}
+	if requestsPerSecond > 0 {
+		t.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return t
+}
+
+// Wrap returns a copy of client whose requests go through a Transport,
+// or a new client if client is nil
+func Wrap(client *http.Client, requestsPerSecond float64) *http.Client {
+	wrapped := &http.Client{}
+	if client != nil {
+		*wrapped = *client
+	}
+	wrapped.Transport = NewTransport(wrapped.Transport, requestsPerSecond)
+	return wrapped
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	retries := t.Retries
+	if req.Context().Value(withoutRetriesKey{}) != nil {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		err := t.wait(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		resp, err := base.RoundTrip(req)
+		if err != nil || !retryable(resp.StatusCode) || !replayable || attempt >= retries {
+			return resp, err
+		}
+
+		delay := retryAfter(resp.Header.Get("Retry-After"))
+		if delay <= 0 {
+			delay = backoff(attempt)
+		}
+		log.Printf("%s%s: %s, retrying in %s\n", req.URL.Host, req.URL.Path, resp.Status, delay.Round(time.Millisecond))
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// wait blocks until the next request is allowed
+func (t *Transport) wait(ctx context.Context) error {
+	if t.interval <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	delay := t.next.Sub(now)
+	t.next = t.next.Add(t.interval)
+	t.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter returns the delay from a Retry-After header, in seconds or as
+// a date, or zero if there isn't one
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+	}
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay
+}
+
+// backoff returns the delay before a retry, doubling each attempt with
+// jitter of up to half so that parallel requests don't retry together
+func backoff(attempt int) time.Duration {
+	delay := retryInterval << uint(attempt)
+	if delay > maxRetryInterval || delay <= 0 {
+		delay = maxRetryInterval
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
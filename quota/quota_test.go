@@ -0,0 +1,138 @@
+package quota
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func fastBackoff(t *testing.T) {
+	interval, max := retryInterval, maxRetryInterval
+	retryInterval, maxRetryInterval = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { retryInterval, maxRetryInterval = interval, max })
+}
+
+// newTestServer fails the first failures requests with status, and checks
+// each request body is body
+func newTestServer(t *testing.T, failures int, status int, body string) (*httptest.Server, *int) {
+	var mu sync.Mutex
+	requests := 0
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		got, _ := io.ReadAll(req.Body)
+		if string(got) != body {
+			t.Errorf("unexpected body %q", got)
+		}
+		if requests <= failures {
+			if status == http.StatusTooManyRequests {
+				rw.Header().Set("Retry-After", "0")
+			}
+			rw.WriteHeader(status)
+			return
+		}
+		rw.Write([]byte("ok"))
+	})), &requests
+}
+
+func TestRetry(t *testing.T) {
+	fastBackoff(t)
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		ts, requests := newTestServer(t, 2, status, "body")
+		client := Wrap(nil, 0)
+		resp, err := client.Post(ts.URL, "text/plain", strings.NewReader("body"))
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Errorf("%d: unexpected response %v %v", status, resp, err)
+		}
+		if *requests != 3 {
+			t.Errorf("%d: unexpected requests %d", status, *requests)
+		}
+		ts.Close()
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	fastBackoff(t)
+	ts, requests := newTestServer(t, 100, http.StatusInternalServerError, "")
+	defer ts.Close()
+
+	resp, err := Wrap(nil, 0).Get(ts.URL)
+	if err != nil || resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("unexpected response %v %v", resp, err)
+	}
+	if *requests != DefaultRetries+1 {
+		t.Errorf("unexpected requests %d", *requests)
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	fastBackoff(t)
+
+	// not a quota or server error
+	//
+	ts, requests := newTestServer(t, 1, http.StatusBadRequest, "")
+	resp, err := Wrap(nil, 0).Get(ts.URL)
+	if err != nil || resp.StatusCode != http.StatusBadRequest || *requests != 1 {
+		t.Errorf("unexpected response %v %v after %d requests", resp, err, *requests)
+	}
+	ts.Close()
+
+	// a body that can't be replayed
+	//
+	ts, requests = newTestServer(t, 1, http.StatusServiceUnavailable, "body")
+	req, _ := http.NewRequest("POST", ts.URL, io.NopCloser(strings.NewReader("body")))
+	resp, err = Wrap(nil, 0).Do(req)
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || *requests != 1 {
+		t.Errorf("unexpected response %v %v after %d requests", resp, err, *requests)
+	}
+	ts.Close()
+	// the caller retries itself
+	//
+	ts, requests = newTestServer(t, 1, http.StatusServiceUnavailable, "")
+	req, _ = http.NewRequestWithContext(WithoutRetries(context.Background()), "GET", ts.URL, nil)
+	resp, err = Wrap(nil, 0).Do(req)
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || *requests != 1 {
+		t.Errorf("unexpected response %v %v after %d requests", resp, err, *requests)
+	}
+	ts.Close()
+}
+
+func TestRateLimit(t *testing.T) {
+	ts, _ := newTestServer(t, 0, 0, "")
+	defer ts.Close()
+
+	client := Wrap(nil, 50)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("unexpected fail %v", err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("not limited, took %s", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if retryAfter("") != 0 || retryAfter("junk") != 0 {
+		t.Errorf("unexpected delay")
+	}
+	if retryAfter("3") != 3*time.Second {
+		t.Errorf("unexpected delay %s", retryAfter("3"))
+	}
+	if retryAfter("3600") != maxRetryAfter {
+		t.Errorf("unexpected delay %s", retryAfter("3600"))
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if delay := retryAfter(date); delay < 58*time.Second || delay > time.Minute {
+		t.Errorf("unexpected delay %s", delay)
+	}
+}
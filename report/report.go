@@ -0,0 +1,184 @@
+// Package report records the outcome of a run for each file.
+//
+// A machine readable record of what happened to each file, written as a
+// single json document or as json lines ( one file per line )
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// Location is where a photo was taken, and where that came from - exif,
+// gpx or manifest
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+	Source    string  `json:"source"`
+}
+
+// Place is a Google place
+type Place struct {
+	PlaceId string `json:"placeId"`
+	Name    string `json:"name"`
+}
+
+// Entry is the outcome for one file
+type Entry struct {
+	File      string     `json:"file"`
+	Outcome   string     `json:"outcome"`
+	Error     string     `json:"error,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	Location  *Location  `json:"location,omitempty"`
+	PhotoId   string     `json:"photoId,omitempty"`
+	ShareLink string     `json:"shareLink,omitempty"`
+	// PublishStatus is the Google Maps publish status of an uploaded photo
+	PublishStatus string   `json:"publishStatus,omitempty"`
+	PlaceId       string   `json:"placeId,omitempty"`
+	Connections   []string `json:"connections,omitempty"`
+	Heading       *float64 `json:"heading,omitempty"`
+	Places        []Place  `json:"places,omitempty"`
+}
+
+// outcomes
+const (
+	Uploaded  = "uploaded"
+	Generated = "generated"
+	Found     = "found"
+	Skipped   = "skipped"
+	Failed    = "failed"
+	Ignored   = "ignored"
+)
+
+// Report is the outcome of a command for each file
+type Report struct {
+	Command  string    `json:"command"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Files    []*Entry  `json:"files"`
+}
+
+// New starts a report for a command
+func New(command string) *Report {
+	return &Report{Command: command, Started: time.Now(), Files: []*Entry{}}
+}
+
+// Add records the outcome for a file.  A nil report records nothing
+func (r *Report) Add(entry *Entry) {
+	if r != nil {
+		r.Files = append(r.Files, entry)
+	}
+}
+
+// SetLocation fills in the timestamp and location of an entry
+func (e *Entry) SetLocation(timestamp time.Time, lat float64, long float64, altitude float64, source string) {
+	e.Timestamp = &timestamp
+	e.Location = &Location{Latitude: lat, Longitude: long, Altitude: altitude, Source: source}
+}
+
+// SetError records the outcome and error for a file that wasn't processed
+func (e *Entry) SetError(outcome string, err error) {
+	e.Outcome = outcome
+	e.Error = err.Error()
+}
+
+// Failed returns true if the file wasn't processed, or had an error.
+// Ignored files, which aren't photos or tracks, haven't failed
+func (e *Entry) Failed() bool {
+	return e.Outcome != Ignored && (e.Outcome == Skipped || e.Outcome == Failed || e.Error != "")
+}
+
+// Counts returns the number of files processed without error, and the
+// number that failed
+func (r *Report) Counts() (int, int) {
+	ok, failed := 0, 0
+	for _, entry := range r.Files {
+		if entry.Failed() {
+			failed++
+		} else if entry.Outcome != Ignored {
+			ok++
+		}
+	}
+	return ok, failed
+}
+
+// Summary writes a table of the outcome and any error for each file, then
+// the totals
+func (r *Report) Summary(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "FILE\tOUTCOME\tERROR\n")
+	for _, entry := range r.Files {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.File, entry.Outcome, entry.Error)
+	}
+	tw.Flush()
+
+	ok, failed := r.Counts()
+	fmt.Fprintf(w, "\n%d ok, %d failed, %d ignored\n", ok, failed, len(r.Files)-ok-failed)
+}
+
+// Write writes the report to a file, as a single json document ( format
+// json ) or as one json line per file ( format jsonl )
+func Write(r *Report, filename string, format string) error {
+	r.Finished = time.Now()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	switch format {
+	case "json":
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	case "jsonl":
+		for _, entry := range r.Files {
+			err = enc.Encode(entry)
+			if err != nil {
+				break
+			}
+		}
+	default:
+		err = fmt.Errorf("invalid report format %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Read reads a report written by Write, in either format
+func Read(filename string) (*Report, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{}
+	err = json.Unmarshal(data, r)
+	if err == nil && r.Command != "" {
+		return r, nil
+	}
+
+	// otherwise one entry per line
+	//
+	r = &Report{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		entry := &Entry{}
+		err = dec.Decode(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		r.Files = append(r.Files, entry)
+	}
+	return r, nil
+}
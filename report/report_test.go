@@ -0,0 +1,125 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	r := New("test")
+	good := &Entry{File: "good.jpg", Outcome: Uploaded, PhotoId: "photoid-1"}
+	good.SetLocation(time.Now(), 51.0, -3.0, 100.0, "exif")
+	r.Add(good)
+	bad := &Entry{File: "bad.jpg"}
+	bad.SetError(Skipped, errors.New("no GPS data"))
+	r.Add(bad)
+	return r
+}
+
+func TestReportNil(t *testing.T) {
+	var r *Report
+	r.Add(&Entry{File: "good.jpg"})
+}
+
+func TestReportJSON(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "report")
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "report.json")
+
+	err := Write(testReport(), filename, "json")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+
+	data, _ := os.ReadFile(filename)
+	var r Report
+	err = json.Unmarshal(data, &r)
+	if err != nil {
+		t.Errorf("invalid json %v", err)
+	}
+	if len(r.Files) != 2 || r.Files[0].Location == nil || r.Files[1].Error != "no GPS data" {
+		t.Errorf("unexpected report %s", string(data))
+	}
+}
+
+func TestReportJSONL(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "report")
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "report.jsonl")
+
+	err := Write(testReport(), filename, "jsonl")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+
+	file, _ := os.Open(filename)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	lines := 0
+	for scanner.Scan() {
+		var entry Entry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			t.Errorf("invalid json line %v", err)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("report.jsonl invalid line count %d", lines)
+	}
+}
+
+func TestReportBadFormat(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "report")
+	defer os.RemoveAll(dir)
+
+	err := Write(testReport(), path.Join(dir, "report.xml"), "xml")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestReportSummary(t *testing.T) {
+	r := testReport()
+	r.Add(&Entry{File: "notes.txt", Outcome: Ignored, Error: "not a photo"})
+	ok, failed := r.Counts()
+	if ok != 1 || failed != 1 {
+		t.Errorf("unexpected counts %d ok, %d failed", ok, failed)
+	}
+
+	var b bytes.Buffer
+	r.Summary(&b)
+	if !strings.Contains(b.String(), "no GPS data") || !strings.Contains(b.String(), "1 ok, 1 failed, 1 ignored") {
+		t.Errorf("unexpected summary %s", b.String())
+	}
+}
+
+func TestReportRead(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "report")
+	defer os.RemoveAll(dir)
+
+	for _, format := range []string{"json", "jsonl"} {
+		filename := path.Join(dir, "report."+format)
+		Write(testReport(), filename, format)
+		r, err := Read(filename)
+		if err != nil {
+			t.Errorf("%s: unexpected fail %v", format, err)
+			continue
+		}
+		if len(r.Files) != 2 || r.Files[0].PhotoId != "photoid-1" {
+			t.Errorf("%s: unexpected files %v", format, r.Files)
+		}
+	}
+
+	_, err := Read("../testdata/junk.gpx")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
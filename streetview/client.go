@@ -0,0 +1,76 @@
+// Package streetview uploads 360 photos to Google Street View with the
+// Street View Publish API, and lists and deletes published photos.
+//
+// See https://developers.google.com/streetview/publish/reference/rest
+package streetview
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+// Client talks to the Street View Publish API
+type Client struct {
+	svc  *streetviewpublish.Service
+	http *http.Client
+}
+
+// NewClient returns a client that makes requests, including uploads, with
+// an authenticated http client such as one from oauth2.Config.Client.  Any
+// options, for example a different endpoint, are passed to the Street View
+// Publish service
+func NewClient(ctx context.Context, client *http.Client, opts ...option.ClientOption) (*Client, error) {
+	svc, err := streetviewpublish.NewService(ctx, append([]option.ClientOption{option.WithHTTPClient(client)}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create StreetViewPublish service: %v", err)
+	}
+	return &Client{svc: svc, http: client}, nil
+}
+
+// batch requests are limited to 20 photos
+const batchSize = 20
+
+// List calls f for each photo published by the account
+func (c *Client) List(ctx context.Context, f func(photo *streetviewpublish.Photo)) error {
+	return c.svc.Photos.List().View("BASIC").Pages(ctx, func(resp *streetviewpublish.ListPhotosResponse) error {
+		for _, photo := range resp.Photos {
+			f(photo)
+		}
+		return nil
+	})
+}
+
+// Delete deletes photos, returning those deleted and an error if any
+// couldn't be deleted
+func (c *Client) Delete(photoIds []string) ([]string, error) {
+	var deleted []string
+	failed := 0
+	for start := 0; start < len(photoIds); start += batchSize {
+		end := start + batchSize
+		if end > len(photoIds) {
+			end = len(photoIds)
+		}
+		resp, err := c.svc.Photos.BatchDelete(&streetviewpublish.BatchDeletePhotosRequest{PhotoIds: photoIds[start:end]}).Do()
+		if err != nil {
+			return deleted, err
+		}
+		for i, status := range resp.Status {
+			if status != nil && status.Code != 0 {
+				log.Printf("%s: Unable to delete: %s\n", photoIds[start+i], status.Message)
+				failed++
+			} else {
+				log.Printf("%s: Deleted\n", photoIds[start+i])
+				deleted = append(deleted, photoIds[start+i])
+			}
+		}
+	}
+	if failed > 0 {
+		return deleted, fmt.Errorf("unable to delete %d of %d photos", failed, len(photoIds))
+	}
+	return deleted, nil
+}
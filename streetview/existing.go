@@ -0,0 +1,76 @@
+// existing photo functions
+//
+// Extending a tour links the new photos into the photos the account has
+// already published - the ends and junctions of the new photos are joined
+// to the nearest published photo, both ways
+
+package streetview
+
+import (
+	"context"
+	"log"
+	"math"
+
+	"github.com/plord12/360tools/geo"
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+// joinExisting joins the ends ( photos with at most one connection ) and
+// junctions ( three or more ) of the new photos to the nearest published
+// photo within radius metres.  The new photos get the existing photo ids
+// in Existing, facing them if they had no heading, and the connections to
+// add to the existing photos are returned
+func (c *Client) joinExisting(ctx context.Context, photos []*PlannedPhoto, photoIdsByFile map[string]string, radius float64) ([]photoConnections, error) {
+	isNew := map[string]bool{}
+	for _, photoId := range photoIdsByFile {
+		isNew[photoId] = true
+	}
+
+	var published []*streetviewpublish.Photo
+	err := c.List(ctx, func(photo *streetviewpublish.Photo) {
+		if _, found := pose(photo); found && !isNew[idOf(photo)] {
+			published = append(published, photo)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var joins []photoConnections
+	index := map[string]int{}
+	for _, photo := range photos {
+		photo.Existing = nil
+		if len(photo.Connections) == 2 {
+			continue
+		}
+
+		var nearest *streetviewpublish.Photo
+		nearestDistance := radius
+		for _, existing := range published {
+			x, y := geo.Displacement(photo.Latitude, photo.Longitude, existing.Pose.LatLngPair.Latitude, existing.Pose.LatLngPair.Longitude)
+			if distance := math.Hypot(x, y); distance <= nearestDistance {
+				nearest, nearestDistance = existing, distance
+			}
+		}
+		if nearest == nil {
+			continue
+		}
+
+		existingId := idOf(nearest)
+		log.Printf("%s: Join to published photo %s, %.1fm away\n", photo.File, existingId, nearestDistance)
+		photo.Existing = []string{existingId}
+		if photo.Heading == nil {
+			bearing := geo.Bearing(photo.Latitude, photo.Longitude, nearest.Pose.LatLngPair.Latitude, nearest.Pose.LatLngPair.Longitude)
+			photo.Heading = &bearing
+		}
+
+		i, found := index[existingId]
+		if !found {
+			i = len(joins)
+			index[existingId] = i
+			joins = append(joins, photoConnections{photoId: existingId, extend: true})
+		}
+		joins[i].targets = append(joins[i].targets, photoIdsByFile[photo.File])
+	}
+	return joins, nil
+}
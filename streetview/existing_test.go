@@ -0,0 +1,43 @@
+package streetview
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestJoinExisting(t *testing.T) {
+	fastPolling(t)
+	counts := testCounts{published: []string{
+		"{\"photoId\": { \"id\": \"photoid-101\" }, \"pose\": { \"latLngPair\": { \"latitude\": 51.42780, \"longitude\": -0.85390 }}}",
+		"{\"photoId\": { \"id\": \"photoid-103\" }, \"pose\": { \"latLngPair\": { \"latitude\": 51.5, \"longitude\": -0.9 }}}",
+	}}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	options := Options{Journal: path.Join(dir, "journal.json"), JoinRadius: 20}
+
+	// a single new photo is joined to the nearby published photo, both ways
+	//
+	err := c.Upload(context.Background(), options, []string{"../testdata/3601.jpg"})
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	added, existing := counts.requests["photoid-1"], counts.requests["photoid-101"]
+	if added == nil || len(added.Photo.Connections) != 1 || added.Photo.Connections[0].Target.Id != "photoid-101" {
+		t.Fatalf("new photo not joined %v", added)
+	}
+	if added.Photo.Pose.Heading == 0 {
+		t.Errorf("new photo not facing the published photo")
+	}
+	if existing == nil || existing.UpdateMask != "connections" || len(existing.Photo.Connections) != 1 || existing.Photo.Connections[0].Target.Id != "photoid-1" {
+		t.Fatalf("published photo not joined %v", existing)
+	}
+	if counts.requests["photoid-103"] != nil {
+		t.Errorf("distant photo joined")
+	}
+}
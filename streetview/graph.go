@@ -0,0 +1,206 @@
+// connection graph functions
+//
+// Photos are linked by one of several strategies -
+//
+//	chain      1st <-> 2nd <-> 3rd ... in order
+//	nearest    each photo <-> its nearest photos within a distance
+//	gabriel    photos <-> photos with no other photo between them
+//	junctions  the chain, plus photos from different passes that are close
+//
+// Distances are worked out on a flat projection around the first photo,
+// which is plenty accurate for a tour
+
+package streetview
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/plord12/360tools/geo"
+)
+
+// Connection strategies, see Options.Connect
+const (
+	ConnectChain     = "chain"
+	ConnectNearest   = "nearest"
+	ConnectGabriel   = "gabriel"
+	ConnectJunctions = "junctions"
+)
+
+// ConnectStrategies are the valid values of Options.Connect
+var ConnectStrategies = []string{ConnectChain, ConnectNearest, ConnectGabriel, ConnectJunctions}
+
+// defaults for Options.Neighbours and Options.MaxDistance
+const (
+	DefaultNeighbours  = 3
+	DefaultMaxDistance = 20.0
+)
+
+type point struct {
+	x float64
+	y float64
+}
+
+func (p point) distance2(q point) float64 {
+	return (p.x-q.x)*(p.x-q.x) + (p.y-q.y)*(p.y-q.y)
+}
+
+func (p point) distance(q point) float64 {
+	return math.Sqrt(p.distance2(q))
+}
+
+// validConnect returns an error if the connection strategy isn't known
+func validConnect(strategy string) error {
+	if strategy == "" {
+		return nil
+	}
+	for _, valid := range ConnectStrategies {
+		if strategy == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid connection strategy %s", strategy)
+}
+
+// project returns the photo locations in metres east and north of the
+// first photo
+func project(photos []*PlannedPhoto) []point {
+	points := make([]point, len(photos))
+	for i, photo := range photos {
+		points[i].x, points[i].y = geo.Displacement(photos[0].Latitude, photos[0].Longitude, photo.Latitude, photo.Longitude)
+	}
+	return points
+}
+
+// strategyLinks returns the pairs of photos to connect, as indexes with the
+// earlier photo first.  The first link from each photo is the one it faces
+func strategyLinks(photos []*PlannedPhoto, options Options) [][2]int {
+	maxDistance := options.MaxDistance
+	if maxDistance <= 0 {
+		maxDistance = DefaultMaxDistance
+	}
+	neighbours := options.Neighbours
+	if neighbours <= 0 {
+		neighbours = DefaultNeighbours
+	}
+
+	switch options.Connect {
+	case ConnectNearest:
+		return nearestLinks(project(photos), neighbours, maxDistance)
+	case ConnectGabriel:
+		return gabrielLinks(project(photos), maxDistance)
+	case ConnectJunctions:
+		return append(chainLinks(len(photos)), junctionLinks(project(photos), maxDistance)...)
+	}
+	return chainLinks(len(photos))
+}
+
+func chainLinks(count int) [][2]int {
+	var links [][2]int
+	for i := 0; i < count-1; i++ {
+		links = append(links, [2]int{i, i + 1})
+	}
+	return links
+}
+
+// sortLinks orders links by their first photo then length, without
+// duplicates, so each photo faces the nearest later photo it links to
+func sortLinks(links [][2]int, points []point) [][2]int {
+	sort.SliceStable(links, func(a, b int) bool {
+		if links[a][0] != links[b][0] {
+			return links[a][0] < links[b][0]
+		}
+		return points[links[a][0]].distance2(points[links[a][1]]) < points[links[b][0]].distance2(points[links[b][1]])
+	})
+	var unique [][2]int
+	for i, link := range links {
+		if i == 0 || link != links[i-1] {
+			unique = append(unique, link)
+		}
+	}
+	return unique
+}
+
+// nearestLinks links each photo to its k nearest photos within maxDistance
+func nearestLinks(points []point, k int, maxDistance float64) [][2]int {
+	var links [][2]int
+	for i := range points {
+		var near []int
+		for j := range points {
+			if j != i && points[i].distance(points[j]) <= maxDistance {
+				near = append(near, j)
+			}
+		}
+		sort.SliceStable(near, func(a, b int) bool {
+			return points[i].distance2(points[near[a]]) < points[i].distance2(points[near[b]])
+		})
+		if len(near) > k {
+			near = near[:k]
+		}
+		for _, j := range near {
+			if i < j {
+				links = append(links, [2]int{i, j})
+			} else {
+				links = append(links, [2]int{j, i})
+			}
+		}
+	}
+	return sortLinks(links, points)
+}
+
+// gabrielLinks links photos within maxDistance of each other when no other
+// photo lies inside the circle between them.  This is the part of the
+// Delaunay triangulation without the long edges across thin triangles, so
+// links don't cut across other photos
+func gabrielLinks(points []point, maxDistance float64) [][2]int {
+	var links [][2]int
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			d2 := points[i].distance2(points[j])
+			if math.Sqrt(d2) > maxDistance {
+				continue
+			}
+			gabriel := true
+			for k := range points {
+				if k != i && k != j && points[i].distance2(points[k])+points[j].distance2(points[k]) < d2 {
+					gabriel = false
+					break
+				}
+			}
+			if gabriel {
+				links = append(links, [2]int{i, j})
+			}
+		}
+	}
+	return sortLinks(links, points)
+}
+
+// junctionLinks links each photo to the nearest photo within maxDistance
+// from a different pass - one more than twice maxDistance away walking
+// along the chain, so photos on the same pass aren't linked again
+func junctionLinks(points []point, maxDistance float64) [][2]int {
+	along := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		along[i] = along[i-1] + points[i-1].distance(points[i])
+	}
+
+	var links [][2]int
+	for i := range points {
+		nearest := -1
+		for j := range points {
+			if math.Abs(along[i]-along[j]) <= 2*maxDistance || points[i].distance(points[j]) > maxDistance {
+				continue
+			}
+			if nearest < 0 || points[i].distance2(points[j]) < points[i].distance2(points[nearest]) {
+				nearest = j
+			}
+		}
+		if nearest > i {
+			links = append(links, [2]int{i, nearest})
+		} else if nearest >= 0 {
+			links = append(links, [2]int{nearest, i})
+		}
+	}
+	return sortLinks(links, points)
+}
@@ -0,0 +1,92 @@
+package streetview
+
+import (
+	"testing"
+
+	"github.com/plord12/360tools/geo"
+)
+
+// plannedPhotos returns photos at x, y metres east and north of a point
+func plannedPhotos(xy ...[2]float64) []*PlannedPhoto {
+	var photos []*PlannedPhoto
+	for i, p := range xy {
+		photo := &PlannedPhoto{File: string(rune('a' + i))}
+		photo.Latitude, photo.Longitude = geo.Location(51.427768, -0.853968, p[0], p[1])
+		photos = append(photos, photo)
+	}
+	return photos
+}
+
+func hasLink(links [][2]int, from int, to int) bool {
+	for _, link := range links {
+		if link == [2]int{from, to} {
+			return true
+		}
+	}
+	return false
+}
+
+func TestChainLinks(t *testing.T) {
+	photos := plannedPhotos([2]float64{0, 0}, [2]float64{100, 0}, [2]float64{200, 0})
+	links := strategyLinks(photos, Options{})
+	if len(links) != 2 || !hasLink(links, 0, 1) || !hasLink(links, 1, 2) {
+		t.Errorf("unexpected links %v", links)
+	}
+}
+
+func TestNearestLinks(t *testing.T) {
+	// a side branch off a path, and a photo too far away
+	//
+	photos := plannedPhotos([2]float64{0, 0}, [2]float64{10, 0}, [2]float64{20, 0}, [2]float64{10, 8}, [2]float64{100, 0})
+	links := strategyLinks(photos, Options{Connect: ConnectNearest, Neighbours: 2})
+	for _, want := range [][2]int{{0, 1}, {1, 2}, {1, 3}} {
+		if !hasLink(links, want[0], want[1]) {
+			t.Errorf("missing link %v in %v", want, links)
+		}
+	}
+	for _, link := range links {
+		if link[1] == 4 {
+			t.Errorf("unexpected link %v", link)
+		}
+	}
+
+	// the first photo faces its nearest later photo
+	//
+	if links[0] != [2]int{0, 1} {
+		t.Errorf("unexpected first link %v", links[0])
+	}
+}
+
+func TestGabrielLinks(t *testing.T) {
+	// the long link is blocked by the photos between
+	//
+	photos := plannedPhotos([2]float64{0, 0}, [2]float64{18, 0}, [2]float64{9, 3}, [2]float64{9, -3})
+	links := strategyLinks(photos, Options{Connect: ConnectGabriel})
+	if hasLink(links, 0, 1) {
+		t.Errorf("unexpected link across in %v", links)
+	}
+	for _, want := range [][2]int{{0, 2}, {0, 3}, {1, 2}, {1, 3}, {2, 3}} {
+		if !hasLink(links, want[0], want[1]) {
+			t.Errorf("missing link %v in %v", want, links)
+		}
+	}
+}
+
+func TestJunctionLinks(t *testing.T) {
+	// a loop back to near the start
+	//
+	photos := plannedPhotos([2]float64{0, 0}, [2]float64{15, 0}, [2]float64{30, 0}, [2]float64{30, 15}, [2]float64{30, 30},
+		[2]float64{15, 30}, [2]float64{0, 30}, [2]float64{0, 15}, [2]float64{0, 2})
+	links := strategyLinks(photos, Options{Connect: ConnectJunctions, MaxDistance: 5})
+	if len(links) != 9 || !hasLink(links, 0, 8) {
+		t.Errorf("unexpected links %v", links)
+	}
+}
+
+func TestPlanInvalidConnect(t *testing.T) {
+	journal, _ := loadJournal("junk.json")
+	_, err := planUpload(Options{Connect: "junk"}, []string{"../testdata/3601.jpg"}, journal)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
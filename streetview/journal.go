@@ -0,0 +1,172 @@
+// upload journal functions
+//
+// The journal records the progress of each photo through an upload so
+// that an interrupted upload can be resumed without uploading anything
+// twice -
+//
+//	file -> upload url -> uploaded -> photo id -> processed -> connections
+//
+// Once every photo of an upload is done its entries are marked finished,
+// so uploading the same files again starts afresh, while delete can still
+// find the photos
+
+package streetview
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type journalEntry struct {
+	File          string   `json:"file"`
+	UploadUrl     string   `json:"uploadUrl,omitempty"`
+	Uploaded      bool     `json:"uploaded,omitempty"`
+	PhotoId       string   `json:"photoId,omitempty"`
+	PlaceId       string   `json:"placeId,omitempty"`
+	Processed     bool     `json:"processed,omitempty"`
+	PublishStatus string   `json:"publishStatus,omitempty"`
+	ShareLink     string   `json:"shareLink,omitempty"`
+	Connected     bool     `json:"connected,omitempty"`
+	Connections   []string `json:"connections,omitempty"`
+	Finished      bool     `json:"finished,omitempty"`
+}
+
+type journal struct {
+	mu       sync.Mutex
+	filename string
+	Entries  []*journalEntry `json:"entries"`
+}
+
+func loadJournal(filename string) (*journal, error) {
+	j := &journal{filename: filename}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, j)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// entry returns the unfinished journal entry for a photo file, adding one
+// if needed
+func (j *journal) entry(file string) *journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	abs := absFile(file)
+	for _, e := range j.Entries {
+		if e.File == abs && !e.Finished {
+			return e
+		}
+	}
+	e := &journalEntry{File: abs}
+	j.Entries = append(j.Entries, e)
+	return e
+}
+
+// finish marks the unfinished entries for the photo files finished, then
+// saves the journal
+func (j *journal) finish(files []string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	finished := map[string]bool{}
+	for _, file := range files {
+		finished[absFile(file)] = true
+	}
+	for _, e := range j.Entries {
+		if finished[e.File] {
+			e.Finished = true
+		}
+	}
+	return j.save()
+}
+
+// absFile returns the absolute path journal entries are kept by
+func absFile(file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	return abs
+}
+
+// photo returns the journal entry for an uploaded photo id, or nil
+func (j *journal) photo(photoId string) *journalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, e := range j.Entries {
+		if e.PhotoId == photoId {
+			return e
+		}
+	}
+	return nil
+}
+
+// update changes entries while holding the journal lock, then saves the
+// journal.  Uploads run in parallel, so entries must only be changed here
+func (j *journal) update(f func()) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f()
+	return j.save()
+}
+
+// save writes the journal, replacing the previous copy only once the new
+// one is complete
+func (j *journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.filename + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.filename)
+}
+
+// remove deletes the journal file
+func (j *journal) remove() error {
+	err := os.Remove(j.filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// prune removes the entries for photos, deleting the journal file if
+// nothing is left
+func (j *journal) prune(photoIds []string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pruned := map[string]bool{}
+	for _, photoId := range photoIds {
+		pruned[photoId] = true
+	}
+	var entries []*journalEntry
+	for _, e := range j.Entries {
+		if e.PhotoId == "" || !pruned[e.PhotoId] {
+			entries = append(entries, e)
+		}
+	}
+	j.Entries = entries
+	if len(j.Entries) == 0 {
+		return j.remove()
+	}
+	return j.save()
+}
@@ -0,0 +1,51 @@
+package streetview
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestJournalNoSuchFile(t *testing.T) {
+	j, err := loadJournal("junk.json")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if len(j.Entries) != 0 {
+		t.Errorf("unexpected entries %d", len(j.Entries))
+	}
+}
+
+func TestJournalJunkFile(t *testing.T) {
+	_, err := loadJournal("../testdata/junk.gpx")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestJournalSave(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "journal.json")
+
+	j, _ := loadJournal(filename)
+	j.entry("testdata/3601.jpg").PhotoId = "photoid-1"
+	err := j.save()
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+
+	j, err = loadJournal(filename)
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if j.entry("testdata/3601.jpg").PhotoId != "photoid-1" {
+		t.Errorf("photo id not saved")
+	}
+	if j.photo("photoid-1") == nil {
+		t.Errorf("photo id not found")
+	}
+	if j.photo("photoid-2") != nil {
+		t.Errorf("unexpected photo id found")
+	}
+}
@@ -0,0 +1,127 @@
+// photo listing functions
+//
+
+package streetview
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+// geoJSON types, see https://geojson.org
+type geoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *geoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string            `json:"type"`
+	Features []*geoJSONFeature `json:"features"`
+}
+
+// placeNames returns the names of the places of a photo, or the place ids
+// if the names aren't known
+func placeNames(photo *streetviewpublish.Photo) []string {
+	var names []string
+	for _, place := range photo.Places {
+		if place.Name != "" {
+			names = append(names, place.Name)
+		} else {
+			names = append(names, place.PlaceId)
+		}
+	}
+	return names
+}
+
+func idOf(photo *streetviewpublish.Photo) string {
+	if photo.PhotoId == nil {
+		return ""
+	}
+	return photo.PhotoId.Id
+}
+
+// pose returns latitude, longitude, altitude, heading, pitch and roll, and
+// false if the photo has no location
+func pose(photo *streetviewpublish.Photo) ([]float64, bool) {
+	if photo.Pose == nil || photo.Pose.LatLngPair == nil {
+		return make([]float64, 6), false
+	}
+	p := photo.Pose
+	return []float64{p.LatLngPair.Latitude, p.LatLngPair.Longitude, p.Altitude, p.Heading, p.Pitch, p.Roll}, true
+}
+
+// PrintPhotos writes photos as a table, csv ( format csv ) or a GeoJSON
+// feature collection ( format geojson )
+func PrintPhotos(w io.Writer, photos []*streetviewpublish.Photo, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "ID\tCAPTURED\tLOCATION\tHEADING\tPLACES\tVIEWS\tSTATUS\tSHARE LINK\n")
+		for _, photo := range photos {
+			location, heading := "", ""
+			if p, found := pose(photo); found {
+				location = fmt.Sprintf("%f, %f, %.1f", p[0], p[1], p[2])
+				heading = fmt.Sprintf("%.1f", p[3])
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", idOf(photo), photo.CaptureTime, location, heading,
+				strings.Join(placeNames(photo), "; "), photo.ViewCount, photo.MapsPublishStatus, photo.ShareLink)
+		}
+		return tw.Flush()
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "captureTime", "latitude", "longitude", "altitude", "heading", "pitch", "roll", "places", "viewCount", "mapsPublishStatus", "shareLink"})
+		for _, photo := range photos {
+			record := []string{idOf(photo), photo.CaptureTime}
+			p, found := pose(photo)
+			for _, value := range p {
+				if found {
+					record = append(record, strconv.FormatFloat(value, 'f', -1, 64))
+				} else {
+					record = append(record, "")
+				}
+			}
+			record = append(record, strings.Join(placeNames(photo), "; "), strconv.FormatInt(photo.ViewCount, 10), photo.MapsPublishStatus, photo.ShareLink)
+			cw.Write(record)
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "geojson":
+		collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []*geoJSONFeature{}}
+		for _, photo := range photos {
+			feature := &geoJSONFeature{Type: "Feature", Properties: map[string]interface{}{
+				"id":                idOf(photo),
+				"captureTime":       photo.CaptureTime,
+				"places":            placeNames(photo),
+				"viewCount":         photo.ViewCount,
+				"mapsPublishStatus": photo.MapsPublishStatus,
+				"shareLink":         photo.ShareLink,
+			}}
+			if p, found := pose(photo); found {
+				feature.Geometry = &geoJSONGeometry{Type: "Point", Coordinates: []float64{p[1], p[0], p[2]}}
+				feature.Properties["heading"] = p[3]
+				feature.Properties["pitch"] = p[4]
+				feature.Properties["roll"] = p[5]
+			}
+			collection.Features = append(collection.Features, feature)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(collection)
+	}
+	return fmt.Errorf("invalid format %s", format)
+}
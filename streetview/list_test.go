@@ -0,0 +1,80 @@
+package streetview
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+func testPhotos(t *testing.T) []*streetviewpublish.Photo {
+	counts := testCounts{}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+
+	var photos []*streetviewpublish.Photo
+	err := newTestClient(ts).List(context.Background(), func(photo *streetviewpublish.Photo) {
+		photos = append(photos, photo)
+	})
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	if len(photos) != 2 {
+		t.Fatalf("unexpected photos %d", len(photos))
+	}
+	return photos
+}
+
+func TestPrintPhotosTable(t *testing.T) {
+	var b bytes.Buffer
+	err := PrintPhotos(&b, testPhotos(t), "table")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], "Old Forest") || !strings.Contains(lines[1], "PUBLISHED") {
+		t.Errorf("unexpected table %s", b.String())
+	}
+}
+
+func TestPrintPhotosCSV(t *testing.T) {
+	var b bytes.Buffer
+	err := PrintPhotos(&b, testPhotos(t), "csv")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid csv %v", err)
+	}
+	if len(records) != 3 || records[1][0] != "photoid-1" || records[1][2] != "51.427768" || records[1][9] != "12" || records[2][2] != "" {
+		t.Errorf("unexpected csv %v", records)
+	}
+}
+
+func TestPrintPhotosGeoJSON(t *testing.T) {
+	var b bytes.Buffer
+	err := PrintPhotos(&b, testPhotos(t), "geojson")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	var collection geoJSONFeatureCollection
+	err = json.Unmarshal(b.Bytes(), &collection)
+	if err != nil {
+		t.Fatalf("invalid json %v", err)
+	}
+	if len(collection.Features) != 2 || collection.Features[0].Geometry.Coordinates[0] != -0.853968 || collection.Features[1].Geometry != nil {
+		t.Errorf("unexpected geojson %s", b.String())
+	}
+}
+
+func TestPrintPhotosBadFormat(t *testing.T) {
+	var b bytes.Buffer
+	if PrintPhotos(&b, nil, "xml") == nil {
+		t.Errorf("didn't fail")
+	}
+}
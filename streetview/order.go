@@ -0,0 +1,139 @@
+// photo ordering functions
+//
+// Photos are connected, and face each other, in order - by default the
+// order they were given in, or -
+//
+//	time   by capture time
+//	track  by how far along the gpx tracks they were taken
+//	path   the shortest path through them, from the first photo given
+
+package streetview
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/plord12/360tools/track"
+)
+
+// Ordering modes, see Options.Order
+const (
+	OrderArguments = "arguments"
+	OrderTime      = "time"
+	OrderTrack     = "track"
+	OrderPath      = "path"
+)
+
+// OrderModes are the valid values of Options.Order
+var OrderModes = []string{OrderArguments, OrderTime, OrderTrack, OrderPath}
+
+// most 2-opt passes over the path, each at least shortening it
+const maxPathPasses = 100
+
+// validOrder returns an error if the ordering mode isn't known
+func validOrder(order string) error {
+	if order == "" {
+		return nil
+	}
+	for _, valid := range OrderModes {
+		if order == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid order %s", order)
+}
+
+// orderPhotos returns the photos in order, using the tracks file for
+// OrderTrack
+func orderPhotos(photos []*PlannedPhoto, order string, tracks string, hasTracks bool) ([]*PlannedPhoto, error) {
+	ordered := append([]*PlannedPhoto{}, photos...)
+
+	switch order {
+	case OrderTime:
+		sort.SliceStable(ordered, func(a, b int) bool {
+			return ordered[a].Timestamp.Before(ordered[b].Timestamp)
+		})
+
+	case OrderTrack:
+		if !hasTracks {
+			return nil, errors.New("ordering by track needs a gpx file")
+		}
+		lats := make([]float64, len(ordered))
+		lons := make([]float64, len(ordered))
+		for i, photo := range ordered {
+			lats[i], lons[i] = photo.Latitude, photo.Longitude
+		}
+		along, err := track.Along(tracks, lats, lons)
+		if err != nil {
+			return nil, fmt.Errorf("unable to order by track - %v", err)
+		}
+		distances := map[*PlannedPhoto]float64{}
+		for i, photo := range ordered {
+			distances[photo] = along[i]
+		}
+		sort.SliceStable(ordered, func(a, b int) bool {
+			return distances[ordered[a]] < distances[ordered[b]]
+		})
+
+	case OrderPath:
+		if len(ordered) > 2 {
+			points := project(ordered)
+			path := shortestPath(points)
+			for i, p := range path {
+				ordered[i] = photos[p]
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// shortestPath returns an order of the points, starting with the first,
+// visiting the nearest unvisited point each time then improved with 2-opt -
+// reversing any part of the path that makes it shorter
+func shortestPath(points []point) []int {
+	path := []int{0}
+	visited := make([]bool, len(points))
+	visited[0] = true
+	for len(path) < len(points) {
+		last := path[len(path)-1]
+		nearest := -1
+		for i := range points {
+			if !visited[i] && (nearest < 0 || points[last].distance2(points[i]) < points[last].distance2(points[nearest])) {
+				nearest = i
+			}
+		}
+		visited[nearest] = true
+		path = append(path, nearest)
+	}
+
+	d := func(a int, b int) float64 {
+		return points[path[a]].distance(points[path[b]])
+	}
+	for pass := 0; pass < maxPathPasses; pass++ {
+		improved := false
+		for i := 0; i < len(path)-2; i++ {
+			for j := i + 2; j < len(path); j++ {
+
+				// replace i -> i+1 and j -> j+1 with i -> j and i+1 -> j+1,
+				// the path is open so there may be no j+1
+				//
+				before, after := d(i, i+1), d(i, j)
+				if j+1 < len(path) {
+					before += d(j, j+1)
+					after += d(i+1, j+1)
+				}
+				if after < before-1e-9 {
+					for a, b := i+1, j; a < b; a, b = a+1, b-1 {
+						path[a], path[b] = path[b], path[a]
+					}
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return path
+}
@@ -0,0 +1,71 @@
+package streetview
+
+import (
+	"testing"
+	"time"
+)
+
+func files(photos []*PlannedPhoto) string {
+	var names string
+	for _, photo := range photos {
+		names += photo.File
+	}
+	return names
+}
+
+func TestOrderTime(t *testing.T) {
+	photos := plannedPhotos([2]float64{0, 0}, [2]float64{10, 0}, [2]float64{20, 0})
+	start := time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)
+	photos[0].Timestamp = start.Add(2 * time.Minute)
+	photos[1].Timestamp = start
+	photos[2].Timestamp = start.Add(time.Minute)
+
+	ordered, err := orderPhotos(photos, OrderTime, "", false)
+	if err != nil || files(ordered) != "bca" {
+		t.Errorf("unexpected order %s %v", files(ordered), err)
+	}
+	if files(photos) != "abc" {
+		t.Errorf("photos changed")
+	}
+}
+
+func TestOrderTrack(t *testing.T) {
+	photos := []*PlannedPhoto{{File: "a", Latitude: 54, Longitude: -6}, {File: "b", Latitude: 50, Longitude: -2}, {File: "c", Latitude: 51, Longitude: -3}}
+	ordered, err := orderPhotos(photos, OrderTrack, "../testdata/good1.gpx", true)
+	if err != nil || files(ordered) != "bca" {
+		t.Errorf("unexpected order %s %v", files(ordered), err)
+	}
+
+	_, err = orderPhotos(photos, OrderTrack, "", false)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestOrderPath(t *testing.T) {
+	// a glob order along a path
+	//
+	photos := plannedPhotos([2]float64{0, 0}, [2]float64{30, 0}, [2]float64{10, 0}, [2]float64{40, 0}, [2]float64{20, 0})
+	ordered, err := orderPhotos(photos, OrderPath, "", false)
+	if err != nil || files(ordered) != "acebd" {
+		t.Errorf("unexpected order %s %v", files(ordered), err)
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	// nearest neighbour goes 0, 1, -2, 4 - 2-opt goes 0, -2, 1, 4
+	//
+	points := []point{{0, 0}, {1, 0}, {-2, 0}, {4, 0}}
+	path := shortestPath(points)
+	if len(path) != 4 || path[0] != 0 || path[1] != 2 || path[2] != 1 || path[3] != 3 {
+		t.Errorf("unexpected path %v", path)
+	}
+}
+
+func TestPlanInvalidOrder(t *testing.T) {
+	journal, _ := loadJournal("junk.json")
+	_, err := planUpload(Options{Order: "junk"}, []string{"../testdata/3601.jpg"}, journal)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
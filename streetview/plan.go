@@ -0,0 +1,282 @@
+// upload plan functions
+//
+// All the local steps of an upload - checking photos, extracting locations
+// and working out connections - without calling Google
+
+package streetview
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/plord12/360tools/geo"
+	"github.com/plord12/360tools/metadata"
+	"github.com/plord12/360tools/track"
+)
+
+// PlannedPhoto is what an upload will do with a photo - skip it, or upload
+// it with a location, place, connections and heading
+type PlannedPhoto struct {
+	File           string    `json:"file"`
+	Skipped        string    `json:"skipped,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	Altitude       float64   `json:"altitude"`
+	Pitch          float64   `json:"pitch"`
+	Roll           float64   `json:"roll"`
+	LocationSource string    `json:"locationSource,omitempty"`
+	PlaceId        string    `json:"placeId,omitempty"`
+	PhotoId        string    `json:"photoId,omitempty"`
+	Connections    []string  `json:"connections,omitempty"`
+	Existing       []string  `json:"existing,omitempty"`
+	Heading        *float64  `json:"heading,omitempty"`
+}
+
+// UploadPlan is what an upload will do with each photo, in order
+type UploadPlan struct {
+	Photos []*PlannedPhoto `json:"photos"`
+}
+
+// Plan works out what Upload would do, without calling Google
+func Plan(options Options, filenames []string) (*UploadPlan, error) {
+	journal, err := loadJournal(options.Journal)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read journal %s - %v", options.Journal, err)
+	}
+	return planUpload(options, filenames, journal)
+}
+
+func planUpload(options Options, filenames []string, journal *journal) (*UploadPlan, error) {
+	plan := &UploadPlan{}
+
+	err := validConnect(options.Connect)
+	if err == nil {
+		err = validOrder(options.Order)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// process gpx files first
+	//
+	tracks, hasTracks, err := track.MergeTemp(filenames)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create tracks.gpx file - %v", err)
+	}
+	defer os.Remove(tracks)
+
+	var uploads []*PlannedPhoto
+	for _, imageFilename := range filenames {
+
+		if metadata.IsPhoto(imageFilename) {
+
+			photo := &PlannedPhoto{File: imageFilename, PlaceId: options.Tour.PlaceIdFor(imageFilename, options.PlaceId)}
+			plan.Photos = append(plan.Photos, photo)
+
+			// note anything a previous run already uploaded
+			//
+			entry := journal.entry(imageFilename)
+			photo.PhotoId = entry.PhotoId
+			if entry.PlaceId != "" {
+				photo.PlaceId = entry.PlaceId
+			}
+
+			// only support 360 images
+			//
+			if !metadata.Is360(imageFilename) {
+				photo.Skipped = "doesn't seem to be a 360 picture"
+				continue
+			}
+
+			// get photo metadata
+			//
+			photo.Timestamp, photo.Latitude, photo.Longitude, photo.Altitude, photo.LocationSource, err = options.Tour.Locate(imageFilename, tracks, hasTracks)
+			if err != nil {
+				photo.Skipped = err.Error()
+				continue
+			}
+
+			// a photo without a pose is level
+			//
+			photo.Pitch, photo.Roll, _ = metadata.Orientation(imageFilename)
+
+			err = assignPlace(photo, options)
+			if err != nil {
+				if options.Strict {
+					return nil, fmt.Errorf("%s: %v", imageFilename, err)
+				}
+				log.Printf("%s: unable to find place, using default: %v", imageFilename, err)
+			}
+
+			uploads = append(uploads, photo)
+		}
+	}
+
+	// put the photos to upload in order, followed by the skipped ones as
+	// given
+	//
+	if options.Order != "" && options.Order != OrderArguments {
+		uploads, err = orderPhotos(uploads, options.Order, tracks, hasTracks)
+		if err != nil {
+			return nil, err
+		}
+		photos := append([]*PlannedPhoto{}, uploads...)
+		for _, photo := range plan.Photos {
+			if photo.Skipped != "" {
+				photos = append(photos, photo)
+			}
+		}
+		plan.Photos = photos
+	}
+
+	// the heading override applies whether or not photos are connected
+	//
+	for _, photo := range uploads {
+		photo.Heading = options.Tour.Heading(photo.File)
+	}
+	if !options.SkipConnections {
+		connectPhotos(uploads, options)
+	}
+
+	return plan, nil
+}
+
+// assignPlace sets the place given for the photo by the tour, or
+// options.PlaceIds, or found near it by options.FindPlace - otherwise
+// leaving the default place.  A photo a previous run uploaded already has
+// its place, so isn't looked up again
+func assignPlace(photo *PlannedPhoto, options Options) error {
+	if photo.PhotoId != "" {
+		return nil
+	}
+	if tourPhoto := options.Tour.Lookup(photo.File); tourPhoto != nil && tourPhoto.PlaceId != "" {
+		return nil
+	}
+	if options.PlaceIds != nil {
+		file, err := filepath.Abs(photo.File)
+		if err == nil && options.PlaceIds[file] != "" {
+			photo.PlaceId = options.PlaceIds[file]
+			return nil
+		}
+	}
+	if options.FindPlace != nil {
+		placeId, err := options.FindPlace(photo.File, photo.Latitude, photo.Longitude)
+		if err != nil {
+			return err
+		}
+		if placeId != "" {
+			photo.PlaceId = placeId
+		}
+	}
+	return nil
+}
+
+// connectPhotos works out the connections and heading of each photo, as
+// addConnections will set them.  Photos are connected both ways along each
+// manifest connection, or without any, as the connection strategy links
+// them.  Each photo faces the first photo it connects to, or if it is only
+// connected to, carries on in the same direction
+func connectPhotos(photos []*PlannedPhoto, options Options) {
+	tour := options.Tour
+	index := map[string]int{}
+	for i, photo := range photos {
+		index[photo.File] = i
+		photo.Connections = nil
+		photo.Heading = nil
+	}
+
+	var links [][2]int
+	edges := tour.Edges()
+	if edges == nil && len(photos) > 0 {
+		links = strategyLinks(photos, options)
+	}
+	for _, edge := range edges {
+		from, fromFound := index[edge[0]]
+		to, toFound := index[edge[1]]
+		if fromFound && toFound {
+			links = append(links, [2]int{from, to})
+		}
+	}
+
+	next := make([]int, len(photos))
+	previous := make([]int, len(photos))
+	for i := range photos {
+		next[i] = -1
+		previous[i] = -1
+	}
+	for _, link := range links {
+		from, to := link[0], link[1]
+		photos[from].Connections = append(photos[from].Connections, photos[to].File)
+		photos[to].Connections = append(photos[to].Connections, photos[from].File)
+		if next[from] < 0 {
+			next[from] = to
+		}
+		if previous[to] < 0 {
+			previous[to] = from
+		}
+	}
+
+	for i, photo := range photos {
+		photo.Heading = tour.Heading(photo.File)
+		if photo.Heading != nil {
+			continue
+		}
+		if next[i] >= 0 {
+			bearing := geo.Bearing(photo.Latitude, photo.Longitude, photos[next[i]].Latitude, photos[next[i]].Longitude)
+			photo.Heading = &bearing
+		} else if previous[i] >= 0 {
+			bearing := geo.Bearing(photos[previous[i]].Latitude, photos[previous[i]].Longitude, photo.Latitude, photo.Longitude)
+			photo.Heading = &bearing
+		}
+	}
+}
+
+// PrintPlan writes a plan as text, or json ( format json )
+func PrintPlan(w io.Writer, plan *UploadPlan, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+
+	uploads := 0
+	for _, photo := range plan.Photos {
+		if photo.Skipped == "" {
+			uploads++
+		}
+	}
+	fmt.Fprintf(w, "Upload plan: %d photos to upload, %d skipped\n\n", uploads, len(plan.Photos)-uploads)
+
+	for _, photo := range plan.Photos {
+		if photo.Skipped != "" {
+			fmt.Fprintf(w, "%s: skipped - %s\n", photo.File, photo.Skipped)
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", photo.File)
+		if photo.PhotoId != "" {
+			fmt.Fprintf(w, "  Already uploaded with id %s\n", photo.PhotoId)
+		}
+		fmt.Fprintf(w, "  Timestamp:   %s\n", photo.Timestamp)
+		fmt.Fprintf(w, "  Location:    %f, %f (from %s)\n", photo.Latitude, photo.Longitude, photo.LocationSource)
+		fmt.Fprintf(w, "  Altitude:    %f\n", photo.Altitude)
+		if photo.Pitch != 0 || photo.Roll != 0 {
+			fmt.Fprintf(w, "  Pitch, roll: %f, %f\n", photo.Pitch, photo.Roll)
+		}
+		if photo.PlaceId != "" {
+			fmt.Fprintf(w, "  Place:       %s\n", photo.PlaceId)
+		}
+		for _, connection := range photo.Connections {
+			fmt.Fprintf(w, "  Connect to:  %s\n", connection)
+		}
+		if photo.Heading != nil {
+			fmt.Fprintf(w, "  Heading:     %f\n", *photo.Heading)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package streetview
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/plord12/360tools/geo"
+	"github.com/plord12/360tools/manifest"
+)
+
+func TestPlan(t *testing.T) {
+	journal, _ := loadJournal("junk.json")
+	plan, err := planUpload(Options{PlaceId: "place"}, []string{"../testdata/3601.jpg", "../testdata/flat1.jpg", "../testdata/nolocation.jpg", "../testdata/good1.gpx"}, journal)
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if len(plan.Photos) != 3 {
+		t.Fatalf("unexpected photos %d", len(plan.Photos))
+	}
+	if plan.Photos[1].Skipped == "" {
+		t.Errorf("flat photo not skipped")
+	}
+	if plan.Photos[2].LocationSource != "gpx" {
+		t.Errorf("unexpected location source %s", plan.Photos[2].LocationSource)
+	}
+	if len(plan.Photos[0].Connections) != 1 || plan.Photos[0].Connections[0] != "../testdata/nolocation.jpg" {
+		t.Errorf("unexpected connections %v", plan.Photos[0].Connections)
+	}
+	if plan.Photos[0].Heading == nil || plan.Photos[0].PlaceId != "place" {
+		t.Errorf("missing heading or place")
+	}
+}
+
+func TestPlanSkipConnections(t *testing.T) {
+	journal, _ := loadJournal("junk.json")
+	plan, err := planUpload(Options{SkipConnections: true}, []string{"../testdata/3601.jpg", "../testdata/nolocation.jpg", "../testdata/good1.gpx"}, journal)
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	for _, photo := range plan.Photos {
+		if len(photo.Connections) != 0 || photo.Heading != nil {
+			t.Errorf("%s: unexpected connections", photo.File)
+		}
+	}
+}
+
+func TestPlanJSON(t *testing.T) {
+	journal, _ := loadJournal("junk.json")
+	plan, _ := planUpload(Options{}, []string{"../testdata/3601.jpg", "../testdata/nolocation.jpg", "../testdata/good1.gpx"}, journal)
+
+	var b bytes.Buffer
+	err := PrintPlan(&b, plan, "json")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	var decoded UploadPlan
+	err = json.Unmarshal(b.Bytes(), &decoded)
+	if err != nil {
+		t.Errorf("invalid json %v", err)
+	}
+	if len(decoded.Photos) != 2 {
+		t.Errorf("unexpected photos %d", len(decoded.Photos))
+	}
+}
+
+func TestPlanManifest(t *testing.T) {
+	tour, _ := manifest.Load("../testdata/tour.yaml")
+	journal, _ := loadJournal("junk.json")
+	plan, err := planUpload(Options{Tour: tour}, tour.Files(), journal)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	if len(plan.Photos) != 3 || plan.Photos[2].Skipped == "" {
+		t.Fatalf("unexpected plan %v", plan.Photos)
+	}
+
+	// explicit connection, with the heading override
+	//
+	first, second := plan.Photos[0], plan.Photos[1]
+	if len(first.Connections) != 1 || first.Connections[0] != second.File || len(second.Connections) != 1 {
+		t.Errorf("unexpected connections %v %v", first.Connections, second.Connections)
+	}
+	if *second.Heading != 90 {
+		t.Errorf("heading not overriden %f", *second.Heading)
+	}
+	if *first.Heading != geo.Bearing(second.Latitude, second.Longitude, first.Latitude, first.Longitude) {
+		t.Errorf("unexpected heading %f", *first.Heading)
+	}
+	// the override still applies without connections
+	//
+	plan, err = planUpload(Options{Tour: tour, SkipConnections: true}, tour.Files(), journal)
+	if err != nil || plan.Photos[1].Heading == nil || *plan.Photos[1].Heading != 90 || plan.Photos[0].Heading != nil {
+		t.Errorf("unexpected headings %v %v", plan.Photos, err)
+	}
+}
+
+func TestPlanPlaces(t *testing.T) {
+	journal, _ := loadJournal("junk.json")
+	file, _ := filepath.Abs("../testdata/3601.jpg")
+	var found []string
+	options := Options{
+		PlaceId:  "default",
+		PlaceIds: map[string]string{file: "listed"},
+		FindPlace: func(file string, latitude float64, longitude float64) (string, error) {
+			found = append(found, file)
+			if file == "../testdata/nolocation.jpg" {
+				return "", nil
+			}
+			return "nearby", nil
+		},
+	}
+	plan, err := planUpload(options, []string{"../testdata/3601.jpg", "../testdata/nolocation.jpg", "../testdata/good1.gpx"}, journal)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	if plan.Photos[0].PlaceId != "listed" || plan.Photos[1].PlaceId != "default" {
+		t.Errorf("unexpected places %s %s", plan.Photos[0].PlaceId, plan.Photos[1].PlaceId)
+	}
+	if len(found) != 1 {
+		t.Errorf("unexpected searches %v", found)
+	}
+
+	// search failures are only fatal when strict
+	//
+	options.PlaceIds = nil
+	options.FindPlace = func(file string, latitude float64, longitude float64) (string, error) {
+		return "", errors.New("denied")
+	}
+	plan, err = planUpload(options, []string{"../testdata/3601.jpg"}, journal)
+	if err != nil || plan.Photos[0].PlaceId != "default" {
+		t.Errorf("unexpected fail %v", err)
+	}
+	options.Strict = true
+	_, err = planUpload(options, []string{"../testdata/3601.jpg"}, journal)
+	if err == nil {
+		t.Errorf("strict search failure succeeded")
+	}
+
+	// photos already uploaded keep their place, without searching
+	//
+	entry := journal.entry("../testdata/3601.jpg")
+	entry.PhotoId, entry.PlaceId = "photoid-1", "uploaded"
+	plan, err = planUpload(options, []string{"../testdata/3601.jpg"}, journal)
+	if err != nil || plan.Photos[0].PlaceId != "uploaded" {
+		t.Errorf("unexpected place %v %v", plan.Photos, err)
+	}
+}
@@ -0,0 +1,102 @@
+// publish status functions
+//
+// Google processes each photo after it is created - until then getting the
+// photo fails.  Once processed the photo can be connected, though it may
+// only be published on Google Maps later, or rejected
+
+package streetview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/plord12/360tools/quota"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+// DefaultPublishTimeout is how long Upload waits for all the photos to be
+// published, if Options.PublishTimeout isn't set
+const DefaultPublishTimeout = 30 * time.Minute
+
+// polling starts at publishPollInterval and doubles up to publishPollMax
+var (
+	publishPollInterval = 1 * time.Second
+	publishPollMax      = 1 * time.Minute
+)
+
+// errRejected is returned for photos Google has rejected
+var errRejected = errors.New("rejected")
+
+// rejection returns an error, with the reason, if Google Maps has rejected
+// the photo.  The transfer status is only about transferring the photo to
+// another account, so isn't checked
+func rejection(photo *streetviewpublish.Photo) error {
+	if photo.MapsPublishStatus == "REJECTED_UNKNOWN" {
+		return fmt.Errorf("%w by Google Maps for an unknown reason", errRejected)
+	}
+	return nil
+}
+
+// publishStatus returns the maps publish status of a processed photo
+func publishStatus(photo *streetviewpublish.Photo) string {
+	if photo.MapsPublishStatus == "" {
+		return "UNSPECIFIED_MAPS_PUBLISH_STATUS"
+	}
+	return photo.MapsPublishStatus
+}
+
+// retryable returns true for errors getting a photo that may go away - not
+// found as the photo isn't processed yet, over quota, Google failing or the
+// request not getting there
+func retryable(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500
+	}
+	return true
+}
+
+// waitPhotoUploaded polls until Google has processed the photo, backing off
+// exponentially, logging its maps publish status.  Polling retries quota
+// and server errors, so a quota.Transport doesn't as well.  Returns an error if the
+// photo is rejected, getting it fails for good, or ctx is done first
+func (c *Client) waitPhotoUploaded(ctx context.Context, photoId string) (*streetviewpublish.Photo, error) {
+
+	log.Printf("%s: Waiting to be processed\n", photoId)
+	interval := publishPollInterval
+	for {
+		photo, err := c.svc.Photo.Get(photoId).View("BASIC").Context(quota.WithoutRetries(ctx)).Do()
+		if err == nil {
+			if err := rejection(photo); err != nil {
+				return nil, err
+			}
+			if status := publishStatus(photo); status == "PUBLISHED" {
+				log.Printf("%s: Published\n", photoId)
+			} else {
+				log.Printf("%s: Processed, maps publish status %s\n", photoId, status)
+			}
+			return photo, nil
+		}
+		if ctx.Err() == nil && !retryable(err) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.New("timed out waiting to be processed")
+			}
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if interval > publishPollMax {
+			interval = publishPollMax
+		}
+	}
+}
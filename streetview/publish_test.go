@@ -0,0 +1,131 @@
+package streetview
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/plord12/360tools/report"
+)
+
+func fastPolling(t *testing.T) {
+	interval, max := publishPollInterval, publishPollMax
+	publishPollInterval, publishPollMax = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { publishPollInterval, publishPollMax = interval, max })
+}
+
+func TestWaitPhotoUploaded(t *testing.T) {
+	fastPolling(t)
+	counts := testCounts{pending: map[string]int{"photoid-1": 3, "photoid-2": -1}, rejected: map[string]string{"photoid-3": "REJECTED_UNKNOWN"}}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	// published after a few polls
+	//
+	photo, err := c.waitPhotoUploaded(context.Background(), "photoid-1")
+	if err != nil || photo == nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if counts.gets != 4 {
+		t.Errorf("unexpected gets %d", counts.gets)
+	}
+
+	// never processed
+	//
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.waitPhotoUploaded(ctx, "photoid-2")
+	if err == nil || err.Error() != "timed out waiting to be processed" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// cancelled
+	//
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = c.waitPhotoUploaded(ctx, "photoid-2")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// rejected
+	//
+	_, err = c.waitPhotoUploaded(context.Background(), "photoid-3")
+	if !errors.Is(err, errRejected) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// processed, but not yet published on maps
+	//
+	photo, err = c.waitPhotoUploaded(context.Background(), "photoid-4")
+	if err != nil || publishStatus(photo) != "UNSPECIFIED_MAPS_PUBLISH_STATUS" {
+		t.Errorf("unexpected status %v %v", photo, err)
+	}
+}
+
+func TestWaitPhotoUploadedFailsFast(t *testing.T) {
+	fastPolling(t)
+	counts := testCounts{getErrors: map[string]int{"photoid-1": 400, "photoid-2": 503}}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	// a bad request won't go away, so isn't retried
+	//
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := c.waitPhotoUploaded(ctx, "photoid-1")
+	if err == nil || err.Error() == "timed out waiting to be processed" || counts.gets != 1 {
+		t.Errorf("unexpected error %v after %d gets", err, counts.gets)
+	}
+
+	// but google failing might
+	//
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.waitPhotoUploaded(ctx, "photoid-2")
+	if err == nil || err.Error() != "timed out waiting to be processed" || counts.gets < 3 {
+		t.Errorf("unexpected error %v after %d gets", err, counts.gets)
+	}
+}
+
+func TestUploadRejected(t *testing.T) {
+	fastPolling(t)
+	counts := testCounts{rejected: map[string]string{"photoid-2": "REJECTED_UNKNOWN"}}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	options := Options{Journal: path.Join(dir, "journal.json"), Report: report.New("upload")}
+
+	// the rejected photo is reported and left out of the connections
+	//
+	err := c.Upload(context.Background(), options, []string{"../testdata/3601.jpg", "../testdata/nolocation.jpg", "../testdata/good1.gpx"})
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if len(options.Report.Files) != 2 || options.Report.Files[0].Outcome != report.Uploaded || options.Report.Files[1].Outcome != report.Failed {
+		t.Fatalf("unexpected report %v", options.Report.Files)
+	}
+	if options.Report.Files[1].PhotoId != "photoid-2" {
+		t.Errorf("unexpected photo id %s", options.Report.Files[1].PhotoId)
+	}
+	if counts.updates != 0 {
+		t.Errorf("unexpected updates %d", counts.updates)
+	}
+
+	// when strict, the upload fails
+	//
+	counts.rejected["photoid-3"] = "REJECTED_UNKNOWN"
+	options = Options{Journal: path.Join(dir, "journal2.json"), Report: report.New("upload"), Strict: true}
+	err = c.Upload(context.Background(), options, []string{"../testdata/3601.jpg"})
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
@@ -0,0 +1,135 @@
+// photo selection functions
+//
+
+package streetview
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+// Bounds is a bounding box, in degrees.  A box with West east of East
+// crosses the antimeridian
+type Bounds struct {
+	South float64
+	West  float64
+	North float64
+	East  float64
+}
+
+// contains returns true if the location is inside the box
+func (b *Bounds) contains(lat float64, long float64) bool {
+	if lat < b.South || lat > b.North {
+		return false
+	}
+	if b.West > b.East {
+		return long >= b.West || long <= b.East
+	}
+	return long >= b.West && long <= b.East
+}
+
+// Selection chooses published photos, for example to delete.  Photos must
+// be one of PhotoIds, if any are given, and inside the bounds and capture
+// time range, if given
+type Selection struct {
+	PhotoIds []string
+	Bounds   *Bounds
+	// From and To limit the capture time, zero for no limit.  To is
+	// exclusive
+	From time.Time
+	To   time.Time
+}
+
+// filtered returns true if photos have to be listed to check them
+func (s Selection) filtered() bool {
+	return s.Bounds != nil || !s.From.IsZero() || !s.To.IsZero()
+}
+
+func (s Selection) matches(photo *streetviewpublish.Photo) bool {
+	if s.Bounds != nil {
+		if photo.Pose == nil || photo.Pose.LatLngPair == nil {
+			return false
+		}
+		if !s.Bounds.contains(photo.Pose.LatLngPair.Latitude, photo.Pose.LatLngPair.Longitude) {
+			return false
+		}
+	}
+	if !s.From.IsZero() || !s.To.IsZero() {
+		captured, err := time.Parse(time.RFC3339, photo.CaptureTime)
+		if err != nil {
+			return false
+		}
+		if !s.From.IsZero() && captured.Before(s.From) {
+			return false
+		}
+		if !s.To.IsZero() && !captured.Before(s.To) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the selected photos.  Photos are only listed, to check
+// their location and capture time, if the selection needs it - otherwise
+// just the photo ids are returned
+func (c *Client) Select(ctx context.Context, s Selection) ([]*streetviewpublish.Photo, error) {
+	var photos []*streetviewpublish.Photo
+	if !s.filtered() {
+		for _, id := range s.PhotoIds {
+			photos = append(photos, &streetviewpublish.Photo{PhotoId: &streetviewpublish.PhotoId{Id: id}})
+		}
+		return photos, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range s.PhotoIds {
+		wanted[id] = true
+	}
+	err := c.List(ctx, func(photo *streetviewpublish.Photo) {
+		if len(wanted) > 0 && !wanted[idOf(photo)] {
+			return
+		}
+		if s.matches(photo) {
+			photos = append(photos, photo)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list photos: %v", err)
+	}
+	return photos, nil
+}
+
+// JournalPhotoIds returns the ids of the photos uploaded in the journal of
+// a previous upload
+func JournalPhotoIds(filename string) ([]string, error) {
+	_, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	journal, err := loadJournal(filename)
+	if err != nil {
+		return nil, err
+	}
+	var photoIds []string
+	for _, entry := range journal.Entries {
+		if entry.PhotoId != "" {
+			photoIds = append(photoIds, entry.PhotoId)
+		}
+	}
+	return photoIds, nil
+}
+
+// PruneJournal removes deleted photos from the journal of a previous
+// upload, so uploading the files again starts afresh.  The journal is
+// removed if no photos are left
+func PruneJournal(filename string, photoIds []string) error {
+	journal, err := loadJournal(filename)
+	if err != nil {
+		return err
+	}
+	return journal.prune(photoIds)
+}
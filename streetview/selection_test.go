@@ -0,0 +1,99 @@
+package streetview
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestSelect(t *testing.T) {
+	counts := testCounts{}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	// just ids doesn't need to list photos
+	//
+	photos, err := c.Select(context.Background(), Selection{PhotoIds: []string{"photoid-9"}})
+	if err != nil || len(photos) != 1 || photos[0].PhotoId.Id != "photoid-9" {
+		t.Errorf("unexpected photos %v - %v", photos, err)
+	}
+
+	// photoid-2 has no location
+	//
+	photos, err = c.Select(context.Background(), Selection{Bounds: &Bounds{South: 51, West: -1, North: 52, East: 0}})
+	if err != nil || len(photos) != 1 || photos[0].PhotoId.Id != "photoid-1" {
+		t.Errorf("unexpected photos %v - %v", photos, err)
+	}
+
+	photos, err = c.Select(context.Background(), Selection{From: time.Date(2022, time.October, 22, 8, 10, 30, 0, time.UTC)})
+	if err != nil || len(photos) != 1 || photos[0].PhotoId.Id != "photoid-2" {
+		t.Errorf("unexpected photos %v - %v", photos, err)
+	}
+
+	photos, err = c.Select(context.Background(), Selection{To: time.Date(2022, time.October, 22, 8, 11, 0, 0, time.UTC)})
+	if err != nil || len(photos) != 1 || photos[0].PhotoId.Id != "photoid-1" {
+		t.Errorf("unexpected photos %v - %v", photos, err)
+	}
+
+	photos, err = c.Select(context.Background(), Selection{PhotoIds: []string{"photoid-2"}, From: time.Date(2022, time.October, 22, 0, 0, 0, 0, time.UTC)})
+	if err != nil || len(photos) != 1 || photos[0].PhotoId.Id != "photoid-2" {
+		t.Errorf("unexpected photos %v - %v", photos, err)
+	}
+}
+
+func TestJournalPhotoIds(t *testing.T) {
+	_, err := JournalPhotoIds("junk.json")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	j, _ := loadJournal(path.Join(dir, "journal.json"))
+	j.entry("../testdata/3601.jpg").PhotoId = "photoid-1"
+	j.entry("../testdata/flat1.jpg")
+	j.save()
+
+	photoIds, err := JournalPhotoIds(path.Join(dir, "journal.json"))
+	if err != nil || len(photoIds) != 1 || photoIds[0] != "photoid-1" {
+		t.Errorf("unexpected photo ids %v - %v", photoIds, err)
+	}
+}
+
+func TestPruneJournal(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "journal.json")
+	j, _ := loadJournal(filename)
+	j.entry("../testdata/3601.jpg").PhotoId = "photoid-1"
+	j.entry("../testdata/nolocation.jpg").PhotoId = "photoid-2"
+	j.save()
+
+	err := PruneJournal(filename, []string{"photoid-1"})
+	photoIds, _ := JournalPhotoIds(filename)
+	if err != nil || len(photoIds) != 1 || photoIds[0] != "photoid-2" {
+		t.Errorf("unexpected photo ids %v - %v", photoIds, err)
+	}
+
+	// nothing left, so the journal goes
+	//
+	err = PruneJournal(filename, []string{"photoid-2"})
+	if _, statErr := os.Stat(filename); err != nil || !os.IsNotExist(statErr) {
+		t.Errorf("journal not removed %v %v", err, statErr)
+	}
+}
+
+func TestBoundsAntimeridian(t *testing.T) {
+	bounds := &Bounds{South: -20, West: 170, North: -10, East: -170}
+	for _, test := range []struct {
+		lat, long float64
+		inside    bool
+	}{{-15, 175, true}, {-15, -175, true}, {-15, 180, true}, {-15, 0, false}, {-15, 160, false}, {0, 175, false}} {
+		if bounds.contains(test.lat, test.long) != test.inside {
+			t.Errorf("%f, %f: unexpected inside %t", test.lat, test.long, !test.inside)
+		}
+	}
+}
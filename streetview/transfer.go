@@ -0,0 +1,235 @@
+// photo transfer functions
+//
+// Photos are streamed to the upload url in a single request, or for large
+// panoramas with Google's resumable upload protocol, a chunk at a time -
+//
+//	start -> upload ... -> upload, finalize
+//
+// Transient failures ( network errors, 408, 429 and 5xx ) are retried with
+// backoff, a resumable upload carrying on from what Google last received -
+// or if it received everything but the upload isn't final, finalizing it.
+// These retries replace any by a quota.Transport, which would otherwise
+// retry the bodiless requests again within each attempt
+
+package streetview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/plord12/360tools/quota"
+)
+
+// files of resumableThreshold bytes or more use the resumable protocol,
+// in chunks of resumableChunkSize.  Failed requests are retried up to
+// uploadAttempts times, starting uploadRetryInterval apart and doubling
+var (
+	resumableThreshold  int64 = 50 << 20
+	resumableChunkSize  int64 = 8 << 20
+	uploadAttempts            = 5
+	uploadRetryInterval       = 1 * time.Second
+)
+
+// statusError is an unexpected http response
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("upload failed with status %d %s", e.status, http.StatusText(e.status))
+	}
+	return fmt.Sprintf("upload failed with status %d %s - %s", e.status, http.StatusText(e.status), e.body)
+}
+
+// transient returns true for upload errors worth retrying
+func transient(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.status == http.StatusRequestTimeout || se.status == http.StatusTooManyRequests || se.status >= 500
+	}
+	return true
+}
+
+// withRetries calls f until it succeeds, fails for good, runs out of
+// attempts or ctx is done
+func withRetries(ctx context.Context, what string, f func() error) error {
+	interval := uploadRetryInterval
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || ctx.Err() != nil || !transient(err) || attempt >= uploadAttempts {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		log.Printf("%s: %v, retrying in %s\n", what, err, interval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+// checkResponse returns an error for any response but 2xx, and closes the
+// body
+func checkResponse(resp *http.Response) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &statusError{status: resp.StatusCode, body: string(body)}
+}
+
+// uploadFile sends the photo bytes to the upload url
+func (c *Client) uploadFile(ctx context.Context, file string, uploadUrl string) error {
+	ctx = quota.WithoutRetries(ctx)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() >= resumableThreshold {
+		return c.uploadResumable(ctx, file, f, info.Size(), uploadUrl)
+	}
+
+	return withRetries(ctx, file, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", uploadUrl, io.NewSectionReader(f, 0, info.Size()))
+		if err != nil {
+			return err
+		}
+		req.ContentLength = info.Size()
+		req.Header.Set("Content-Type", "image/jpeg")
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		return checkResponse(resp)
+	})
+}
+
+// uploadResumable sends the photo bytes a chunk at a time
+func (c *Client) uploadResumable(ctx context.Context, file string, f io.ReaderAt, size int64, uploadUrl string) error {
+
+	// start the session
+	//
+	var sessionUrl string
+	err := withRetries(ctx, file, func() error {
+		resp, err := c.uploadCommand(ctx, uploadUrl, "start", 0, nil, map[string]string{
+			"X-Goog-Upload-Header-Content-Length": strconv.FormatInt(size, 10),
+			"X-Goog-Upload-Header-Content-Type":   "image/jpeg",
+		})
+		if err != nil {
+			return err
+		}
+		sessionUrl = resp.Header.Get("X-Goog-Upload-URL")
+		if sessionUrl == "" {
+			return errors.New("no resumable upload url returned")
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to start resumable upload: %v", err)
+	}
+
+	// send each chunk, after a failure asking google how much it has
+	//
+	var offset int64
+	failed := false
+	return withRetries(ctx, file, func() error {
+		if failed {
+			received, status, err := c.uploadReceived(ctx, sessionUrl)
+			if err != nil {
+				return err
+			}
+			offset = received
+			failed = false
+			if status == "final" {
+				return nil
+			}
+
+			// every byte arrived, but the upload wasn't finalized
+			//
+			if offset >= size {
+				_, err = c.uploadCommand(ctx, sessionUrl, "finalize", offset, io.NewSectionReader(f, offset, 0), nil)
+				if err != nil {
+					failed = true
+				}
+				return err
+			}
+		}
+		for offset < size {
+			length := resumableChunkSize
+			command := "upload"
+			if offset+length >= size {
+				length = size - offset
+				command = "upload, finalize"
+			}
+			_, err := c.uploadCommand(ctx, sessionUrl, command, offset, io.NewSectionReader(f, offset, length), nil)
+			if err != nil {
+				failed = true
+				return err
+			}
+			offset += length
+			log.Printf("%s: Uploaded %d of %d bytes\n", file, offset, size)
+		}
+		return nil
+	})
+}
+
+// uploadReceived returns how many bytes of a resumable upload google has,
+// and the upload status - active, or final once finalized
+func (c *Client) uploadReceived(ctx context.Context, sessionUrl string) (int64, string, error) {
+	resp, err := c.uploadCommand(ctx, sessionUrl, "query", 0, nil, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	received, err := strconv.ParseInt(resp.Header.Get("X-Goog-Upload-Size-Received"), 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid upload size received - %v", err)
+	}
+	return received, resp.Header.Get("X-Goog-Upload-Status"), nil
+}
+
+// uploadCommand makes one request of the resumable protocol
+func (c *Client) uploadCommand(ctx context.Context, url string, command string, offset int64, body *io.SectionReader, headers map[string]string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = body
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = body.Size()
+		req.Header.Set("X-Goog-Upload-Offset", strconv.FormatInt(offset, 10))
+	}
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	req.Header.Set("X-Goog-Upload-Command", command)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp, checkResponse(resp)
+}
@@ -0,0 +1,140 @@
+package streetview
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/plord12/360tools/quota"
+	"github.com/plord12/360tools/report"
+	"google.golang.org/api/option"
+)
+
+func fastRetries(t *testing.T) {
+	interval := uploadRetryInterval
+	uploadRetryInterval = time.Millisecond
+	t.Cleanup(func() { uploadRetryInterval = interval })
+}
+
+func TestUploadFile(t *testing.T) {
+	fastRetries(t)
+	counts := testCounts{uploadFailures: 2}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	// retried after failures
+	//
+	err := c.uploadFile(context.Background(), "../testdata/3601.jpg", ts.URL+"/upload/uploadreference")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	info, _ := os.Stat("../testdata/3601.jpg")
+	if counts.uploaded != info.Size() {
+		t.Errorf("unexpected size %d", counts.uploaded)
+	}
+
+	// too many failures
+	//
+	counts.uploadFailures = uploadAttempts
+	err = c.uploadFile(context.Background(), "../testdata/3601.jpg", ts.URL+"/upload/uploadreference")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+
+	// missing file
+	//
+	err = c.uploadFile(context.Background(), "../testdata/missing.jpg", ts.URL+"/upload/uploadreference")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestUploadFileQuota(t *testing.T) {
+	fastRetries(t)
+	counts := testCounts{uploadFailures: 100}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c, err := NewClient(context.Background(), quota.Wrap(nil, 0), option.WithEndpoint(ts.URL+"/streetviewpublish"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("unable to create client %v", err)
+	}
+
+	// only retried here, not by the quota transport as well
+	//
+	err = c.uploadFile(context.Background(), "../testdata/3601.jpg", ts.URL+"/upload/uploadreference")
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+	if sent := 100 - counts.uploadFailures; sent != uploadAttempts {
+		t.Errorf("unexpected uploads %d", sent)
+	}
+}
+
+func TestUploadFileResumable(t *testing.T) {
+	fastRetries(t)
+	threshold, chunkSize := resumableThreshold, resumableChunkSize
+	resumableThreshold, resumableChunkSize = 1<<20, 1<<20
+	defer func() { resumableThreshold, resumableChunkSize = threshold, chunkSize }()
+
+	counts := testCounts{uploadFailures: 1}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	// the failed first chunk was received, so isn't sent again
+	//
+	err := c.uploadFile(context.Background(), "../testdata/3601.jpg", ts.URL+"/upload/uploadreference")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	info, _ := os.Stat("../testdata/3601.jpg")
+	if counts.uploaded != info.Size() || counts.chunks != 4 {
+		t.Errorf("unexpected size %d in %d chunks", counts.uploaded, counts.chunks)
+	}
+
+	// the last chunk was received but the upload not finalized, so it is
+	// finalized without sending the chunk again
+	//
+	counts = testCounts{failFinal: true}
+	err = c.uploadFile(context.Background(), "../testdata/3601.jpg", ts.URL+"/upload/uploadreference")
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if counts.uploaded != info.Size() || counts.chunks != 4 || counts.finalizes != 1 || !counts.finalized {
+		t.Errorf("unexpected size %d in %d chunks, %d finalizes", counts.uploaded, counts.chunks, counts.finalizes)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	for status, want := range map[int]bool{http.StatusBadRequest: false, http.StatusForbidden: false, http.StatusTooManyRequests: true, http.StatusBadGateway: true} {
+		if transient(&statusError{status: status}) != want {
+			t.Errorf("%d: expected %v", status, want)
+		}
+	}
+}
+
+func TestUploadFileFailed(t *testing.T) {
+	fastRetries(t)
+	counts := testCounts{uploadFailures: 100}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	options := Options{Journal: path.Join(dir, "journal.json"), Report: report.New("upload")}
+
+	// the photo fails, but not the whole upload
+	//
+	err := c.Upload(context.Background(), options, []string{"../testdata/3601.jpg"})
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if len(options.Report.Files) != 1 || options.Report.Files[0].Outcome != report.Failed || counts.creates != 0 {
+		t.Errorf("unexpected report %v", options.Report.Files)
+	}
+}
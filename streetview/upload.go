@@ -0,0 +1,579 @@
+// upload functions
+//
+
+package streetview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plord12/360tools/manifest"
+	"github.com/plord12/360tools/report"
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+// Options control an upload
+type Options struct {
+	// SkipConnections uploads the photos without connecting them
+	SkipConnections bool
+	// PlaceId is the Google place to add to each photo, unless the tour
+	// gives one
+	PlaceId string
+	// PlaceIds, if not nil, is the place for each photo by absolute file
+	// name, used unless the tour gives the photo one
+	PlaceIds map[string]string
+	// FindPlace, if not nil, finds the place for photos not given one by
+	// the tour or PlaceIds, returning an empty place id for none nearby
+	FindPlace func(file string, latitude float64, longitude float64) (string, error)
+	// Journal is the file recording upload progress, so an interrupted
+	// upload can be resumed
+	Journal string
+	// Workers is the number of photos to upload in parallel
+	Workers int
+	// Report, if not nil, records the outcome for each photo
+	Report *report.Report
+	// Tour, if not nil, gives overrides and connections for the photos
+	Tour *manifest.Manifest
+	// Strict stops the upload at the first photo that can't be uploaded
+	// or connected, rather than skipping it
+	Strict bool
+	// Order is how the photos are ordered for connecting, one of
+	// OrderModes - OrderArguments if empty
+	Order string
+	// Connect is the connection strategy, one of ConnectStrategies -
+	// ConnectChain if empty.  Ignored if the tour gives connections
+	Connect string
+	// Neighbours is the most photos each photo is connected to with
+	// ConnectNearest, DefaultNeighbours if zero
+	Neighbours int
+	// MaxDistance is the furthest, in metres, photos are connected with
+	// ConnectNearest and ConnectGabriel, and the junction distance with
+	// ConnectJunctions.  DefaultMaxDistance if zero
+	MaxDistance float64
+	// JoinRadius, if not zero, joins the ends and junctions of the new
+	// photos to photos already published within this many metres
+	JoinRadius float64
+	// PublishTimeout limits how long to wait for all the photos to be
+	// published, DefaultPublishTimeout if zero
+	PublishTimeout time.Duration
+}
+
+// Upload uploads the photos, using any gpx tracks among the files for photos
+// without a location, then connects each photo to the next in order ( or as
+// the tour says ).  Photos that can't be uploaded are skipped and recorded in the
+// report, the error is only for problems with the whole upload - unless
+// strict, when the first problem with any photo stops the upload.  Photos
+// that aren't published are left out of the connections.  Cancelling ctx
+// stops the upload
+func (c *Client) Upload(ctx context.Context, options Options, filenames []string) error {
+	journal, err := loadJournal(options.Journal)
+	if err != nil {
+		return fmt.Errorf("unable to read journal %s - %v", options.Journal, err)
+	}
+
+	// work out what to do before talking to google
+	//
+	plan, err := planUpload(options, filenames, journal)
+	if err != nil {
+		return err
+	}
+
+	// upload in parallel, keeping the results in plan order so that
+	// connections don't depend on which upload finished first
+	//
+	photoIds := make([]string, len(plan.Photos))
+	uploadErrors := make([]error, len(plan.Photos))
+	publishErrors := make([]error, len(plan.Photos))
+	connectionErrors := map[string]error{}
+	defer func() {
+		reportUpload(options.Report, plan, journal, photoIds, uploadErrors, publishErrors, connectionErrors)
+	}()
+
+	// when strict, don't upload anything if any photo would be skipped
+	//
+	if options.Strict {
+		for _, photo := range plan.Photos {
+			if photo.Skipped != "" {
+				return fmt.Errorf("%s: %s", photo.File, photo.Skipped)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var abort error
+	parallel(len(plan.Photos), options.Workers, func(i int) {
+		photo := plan.Photos[i]
+		if photo.Skipped != "" {
+			log.Printf("%s: %s, skipping picture\n", photo.File, photo.Skipped)
+			return
+		}
+		mu.Lock()
+		if abort == nil && ctx.Err() != nil {
+			abort = ctx.Err()
+		}
+		aborted := abort != nil
+		mu.Unlock()
+		if aborted {
+			return
+		}
+		photoId, err := c.uploadPhoto(ctx, photo, journal)
+		if err != nil {
+			log.Printf("%s: %v, skipping picture\n", photo.File, err)
+			uploadErrors[i] = err
+			if options.Strict {
+				mu.Lock()
+				if abort == nil {
+					abort = fmt.Errorf("%s: %v", photo.File, err)
+				}
+				mu.Unlock()
+			}
+			return
+		}
+		photoIds[i] = photoId
+	})
+	if abort != nil {
+		return abort
+	}
+
+	// wait for index complete, for all the photos together
+	//
+	timeout := options.PublishTimeout
+	if timeout <= 0 {
+		timeout = DefaultPublishTimeout
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	parallel(len(plan.Photos), options.Workers, func(i int) {
+		if photoIds[i] == "" {
+			return
+		}
+		entry := journal.photo(photoIds[i])
+		if !entry.Processed {
+			photo, err := c.waitPhotoUploaded(waitCtx, photoIds[i])
+			if err != nil {
+				log.Printf("%s: %s not processed: %v\n", plan.Photos[i].File, photoIds[i], err)
+				publishErrors[i] = err
+				return
+			}
+			updateJournal(journal, func() {
+				entry.Processed = true
+				entry.PublishStatus = publishStatus(photo)
+				entry.ShareLink = photo.ShareLink
+			})
+		}
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if options.Strict {
+		for i, err := range publishErrors {
+			if err != nil {
+				return fmt.Errorf("%s: not processed: %v", plan.Photos[i].File, err)
+			}
+		}
+	}
+
+	// fix metadata by adding connections and bearings
+	//
+	joinFailed := false
+	if !options.SkipConnections {
+		var uploaded []*PlannedPhoto
+		photoIdsByFile := map[string]string{}
+		for i, photo := range plan.Photos {
+			if photoIds[i] != "" && publishErrors[i] == nil {
+				uploaded = append(uploaded, photo)
+				photoIdsByFile[photo.File] = photoIds[i]
+			}
+		}
+
+		// connect just the photos that were uploaded and published
+		//
+		connectPhotos(uploaded, options)
+		var connections []photoConnections
+		if options.JoinRadius > 0 {
+			joins, err := c.joinExisting(ctx, uploaded, photoIdsByFile, options.JoinRadius)
+			if err != nil {
+				if options.Strict {
+					return fmt.Errorf("unable to list published photos: %v", err)
+				}
+				log.Printf("Unable to list published photos, not joining to them: %v\n", err)
+				joinFailed = true
+			}
+			connections = append(connections, joins...)
+		}
+		for _, photo := range uploaded {
+			if photo.Heading == nil {
+				continue
+			}
+			connection := photoConnections{photoId: photoIdsByFile[photo.File], heading: *photo.Heading, pitch: photo.Pitch, roll: photo.Roll}
+			for _, file := range photo.Connections {
+				connection.targets = append(connection.targets, photoIdsByFile[file])
+			}
+			connection.targets = append(connection.targets, photo.Existing...)
+			connections = append(connections, connection)
+		}
+		connectionErrors = c.addConnections(connections, journal, options.Strict)
+		if options.Strict && len(connectionErrors) > 0 {
+			for photoId, err := range connectionErrors {
+				return fmt.Errorf("%s: unable to add connections: %v", photoId, err)
+			}
+		}
+	}
+
+	// a finished upload has nothing to resume, so its entries are marked
+	// finished - otherwise a later upload of the same photos would reuse
+	// their photo ids, even once the photos are deleted
+	//
+	complete := len(connectionErrors) == 0 && !joinFailed
+	var files []string
+	for i, photo := range plan.Photos {
+		if photo.Skipped != "" || photoIds[i] == "" || publishErrors[i] != nil {
+			complete = false
+		}
+		files = append(files, photo.File)
+	}
+	if complete {
+		err = journal.finish(files)
+		if err != nil {
+			log.Printf("Unable to update journal %s: %v", options.Journal, err)
+		}
+	} else {
+		log.Printf("Not every photo was uploaded and connected, run again with journal %s to carry on", options.Journal)
+	}
+
+	return nil
+}
+
+// reportUpload adds the outcome of each photo in the plan to the report
+func reportUpload(runReport *report.Report, plan *UploadPlan, journal *journal, photoIds []string, uploadErrors []error, publishErrors []error, connectionErrors map[string]error) {
+	for i, photo := range plan.Photos {
+		entry := &report.Entry{File: photo.File, Outcome: report.Uploaded, PlaceId: photo.PlaceId}
+		if photo.Skipped != "" {
+			entry.Outcome = report.Skipped
+			entry.Error = photo.Skipped
+			runReport.Add(entry)
+			continue
+		}
+		entry.SetLocation(photo.Timestamp, photo.Latitude, photo.Longitude, photo.Altitude, photo.LocationSource)
+		if uploadErrors[i] != nil {
+			entry.SetError(report.Failed, uploadErrors[i])
+			runReport.Add(entry)
+			continue
+		}
+		if photoIds[i] == "" {
+			entry.SetError(report.Skipped, errors.New("not uploaded after an earlier error"))
+			runReport.Add(entry)
+			continue
+		}
+		journalEntry := journal.photo(photoIds[i])
+		entry.PhotoId = journalEntry.PhotoId
+		entry.ShareLink = journalEntry.ShareLink
+		entry.PublishStatus = journalEntry.PublishStatus
+		if publishErrors[i] != nil {
+			entry.SetError(report.Failed, fmt.Errorf("not processed: %v", publishErrors[i]))
+			runReport.Add(entry)
+			continue
+		}
+		if journalEntry.Connected {
+			entry.Connections = journalEntry.Connections
+			entry.Heading = photo.Heading
+		}
+		if err, failed := connectionErrors[entry.PhotoId]; failed {
+			entry.Error = fmt.Sprintf("unable to add connections: %v", err)
+		}
+		runReport.Add(entry)
+	}
+}
+
+func (c *Client) uploadPhoto(ctx context.Context, photo *PlannedPhoto, journal *journal) (string, error) {
+
+	// skip anything a previous run already uploaded
+	//
+	entry := journal.entry(photo.File)
+	if entry.PhotoId != "" {
+		log.Printf("%s: Already uploaded with id %s\n", photo.File, entry.PhotoId)
+		return entry.PhotoId, nil
+	}
+
+	log.Printf("%s: Timestamp %s\n", photo.File, photo.Timestamp)
+	log.Printf("%s: Latitude %f, Longitude %f\n", photo.File, photo.Latitude, photo.Longitude)
+	log.Printf("%s: Altitude %f\n", photo.File, photo.Altitude)
+
+	// get upload url
+	//
+	uploadUrl := entry.UploadUrl
+	if uploadUrl == "" {
+		var err error
+		uploadUrl, err = c.getUploadUrl()
+		if err != nil {
+			return "", fmt.Errorf("unable to StartUpload: %v", err)
+		}
+		updateJournal(journal, func() { entry.UploadUrl = uploadUrl })
+	}
+
+	// upload file
+	//
+	if !entry.Uploaded {
+		err := c.uploadFile(ctx, photo.File, uploadUrl)
+		if err != nil {
+			return "", fmt.Errorf("unable to upload file: %v", err)
+		}
+		log.Printf("%s: Uploaded\n", photo.File)
+		updateJournal(journal, func() { entry.Uploaded = true })
+	}
+
+	// create meta data
+	//
+	photoId, err := c.createPhoto(uploadUrl, photo)
+	if err != nil {
+		return "", fmt.Errorf("unable to upload metadata: %v", err)
+	}
+	log.Printf("%s: Created metadata with id %s\n", photo.File, photoId)
+	updateJournal(journal, func() {
+		entry.PhotoId = photoId
+		entry.PlaceId = photo.PlaceId
+	})
+
+	return photoId, nil
+}
+
+func updateJournal(journal *journal, f func()) {
+	err := journal.update(f)
+	if err != nil {
+		log.Printf("Warning: failed to save journal: %v", err)
+	}
+}
+
+// parallel calls f for 0 to count-1 using at most workers goroutines
+func parallel(count int, workers int, f func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+func (c *Client) getUploadUrl() (string, error) {
+	uploadRef, err := c.svc.Photo.StartUpload(&streetviewpublish.Empty{}).Do()
+	if err != nil {
+		return "", err
+	}
+
+	return uploadRef.UploadUrl, nil
+}
+
+func (c *Client) createPhoto(uploadUrl string, planned *PlannedPhoto) (string, error) {
+	photo := streetviewpublish.Photo{
+		UploadReference: &streetviewpublish.UploadRef{UploadUrl: uploadUrl},
+		Pose: &streetviewpublish.Pose{
+			LatLngPair: &streetviewpublish.LatLng{Latitude: planned.Latitude, Longitude: planned.Longitude},
+			Altitude:   planned.Altitude,
+			Pitch:      planned.Pitch,
+			Roll:       planned.Roll},
+		CaptureTime: planned.Timestamp.Format("2006-01-02T15:04:05Z")}
+	if planned.Heading != nil {
+		photo.Pose.Heading = *planned.Heading
+		photo.Pose.ForceSendFields = []string{"Heading"}
+	}
+	if len(planned.PlaceId) > 0 {
+		place := streetviewpublish.Place{PlaceId: planned.PlaceId}
+		photo.Places = []*streetviewpublish.Place{&place}
+	}
+	resp, err := c.svc.Photo.Create(&photo).Do()
+	if err != nil {
+		return "", err
+	}
+	return resp.PhotoId.Id, nil
+}
+
+type photoConnections struct {
+	photoId string
+	targets []string
+	heading float64
+	pitch   float64
+	roll    float64
+	// extend adds the targets to the photo's connections, leaving its
+	// pose alone - for photos published before
+	extend bool
+}
+
+// addConnections sets the connections and heading of each photo, by
+// default a chain -
+//
+//	1st -> 2nd
+//	2nd -> 1st
+//	2nd -> 3rd
+//	...
+//	last -> n-1
+//
+// Photos are fetched and updated in batches, returning the error for each
+// photo that couldn't be updated.  When strict, stops after the first batch
+// with an error
+func (c *Client) addConnections(connections []photoConnections, journal *journal, strict bool) map[string]error {
+	failed := map[string]error{}
+
+	// skip photos a previous run already connected the same way
+	//
+	var todo []photoConnections
+	for _, connection := range connections {
+		entry := journal.photo(connection.photoId)
+		if entry != nil && entry.Connected && strings.Join(entry.Connections, ",") == strings.Join(connection.targets, ",") {
+			log.Printf("%s: Already connected\n", connection.photoId)
+			continue
+		}
+		todo = append(todo, connection)
+	}
+
+	for start := 0; start < len(todo); start += batchSize {
+		end := start + batchSize
+		if end > len(todo) {
+			end = len(todo)
+		}
+		batch := todo[start:end]
+
+		var photoIds []string
+		for _, connection := range batch {
+			photoIds = append(photoIds, connection.photoId)
+		}
+		got, err := c.svc.Photos.BatchGet().PhotoIds(photoIds...).View("BASIC").Do()
+		if err == nil && len(got.Results) != len(batch) {
+			err = fmt.Errorf("expected %d photos, got %d", len(batch), len(got.Results))
+		}
+		if err != nil {
+			log.Printf("Unable to get photos: %v", err)
+			for _, photoId := range photoIds {
+				failed[photoId] = err
+			}
+			if strict {
+				break
+			}
+			continue
+		}
+
+		var updates []*streetviewpublish.UpdatePhotoRequest
+		var updating []photoConnections
+		for i, result := range got.Results {
+			connection := batch[i]
+			photo, err := photoResult(result)
+			if err != nil {
+				log.Printf("%s: Unable to get photo: %v\n", connection.photoId, err)
+				failed[connection.photoId] = err
+				continue
+			}
+
+			if connection.extend {
+				added := false
+				for _, target := range connection.targets {
+					if !connectedTo(photo, target) {
+						photo.Connections = append(photo.Connections, &streetviewpublish.Connection{Target: &streetviewpublish.PhotoId{Id: target}})
+						added = true
+					}
+				}
+				if !added {
+					log.Printf("%s: Already connected\n", connection.photoId)
+					continue
+				}
+				log.Printf("%s: Also connect to %s\n", connection.photoId, strings.Join(connection.targets, ", "))
+				updates = append(updates, &streetviewpublish.UpdatePhotoRequest{Photo: photo, UpdateMask: "connections"})
+				updating = append(updating, connection)
+				continue
+			}
+
+			photo.Connections = nil
+			for _, target := range connection.targets {
+				photo.Connections = append(photo.Connections, &streetviewpublish.Connection{Target: &streetviewpublish.PhotoId{Id: target}})
+			}
+
+			// only the heading, pitch and roll change, but the rest of the
+			// pose is kept as the api needs the location alongside it
+			//
+			if photo.Pose == nil {
+				photo.Pose = &streetviewpublish.Pose{}
+			}
+			photo.Pose.Heading = connection.heading
+			photo.Pose.Pitch = connection.pitch
+			photo.Pose.Roll = connection.roll
+			log.Printf("%s: Connect to %s, bearing %f\n", connection.photoId, strings.Join(connection.targets, ", "), connection.heading)
+
+			updates = append(updates, &streetviewpublish.UpdatePhotoRequest{Photo: photo, UpdateMask: "connections,pose.heading,pose.pitch,pose.roll"})
+			updating = append(updating, connection)
+		}
+
+		if len(updates) > 0 {
+			updated, err := c.svc.Photos.BatchUpdate(&streetviewpublish.BatchUpdatePhotosRequest{UpdatePhotoRequests: updates}).Do()
+			if err == nil && len(updated.Results) != len(updating) {
+				err = fmt.Errorf("expected %d photos, got %d", len(updating), len(updated.Results))
+			}
+			if err != nil {
+				log.Printf("Unable to Update metadata: %v", err)
+				for _, connection := range updating {
+					failed[connection.photoId] = err
+				}
+			} else {
+				for i, result := range updated.Results {
+					connection := updating[i]
+					_, err := photoResult(result)
+					if err != nil {
+						log.Printf("%s: Unable to Update metadata: %v\n", connection.photoId, err)
+						failed[connection.photoId] = err
+						continue
+					}
+					log.Printf("%s: Connected\n", connection.photoId)
+					if entry := journal.photo(connection.photoId); entry != nil {
+						updateJournal(journal, func() {
+							entry.Connected = true
+							entry.Connections = connection.targets
+						})
+					}
+				}
+			}
+		}
+
+		if strict && len(failed) > 0 {
+			break
+		}
+	}
+
+	return failed
+}
+
+// connectedTo returns true if the photo is already connected to target
+func connectedTo(photo *streetviewpublish.Photo, target string) bool {
+	for _, connection := range photo.Connections {
+		if connection.Target != nil && connection.Target.Id == target {
+			return true
+		}
+	}
+	return false
+}
+
+// photoResult returns the photo from one result of a batch request, or the
+// error for it
+func photoResult(result *streetviewpublish.PhotoResponse) (*streetviewpublish.Photo, error) {
+	if result.Status != nil && result.Status.Code != 0 {
+		return nil, fmt.Errorf("%s ( code %d )", result.Status.Message, result.Status.Code)
+	}
+	if result.Photo == nil {
+		return nil, errors.New("no photo returned")
+	}
+	return result.Photo, nil
+}
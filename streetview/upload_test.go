@@ -0,0 +1,404 @@
+package streetview
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/plord12/360tools/manifest"
+	"github.com/plord12/360tools/report"
+	"google.golang.org/api/option"
+	"google.golang.org/api/streetviewpublish/v1"
+)
+
+type testCounts struct {
+	sync.Mutex
+	startUploads int
+	creates      int
+	updates      int
+	batches      int
+	requests     map[string]*streetviewpublish.UpdatePhotoRequest
+	// pending is the number of gets of a photo that fail before it is
+	// published, -1 for never
+	pending map[string]int
+	// rejected photos have this maps publish status
+	rejected map[string]string
+	// getErrors are http errors getting photos
+	getErrors map[string]int
+	gets      int
+	// uploadFailures is the number of uploads, or chunks, that fail with
+	// 503 - a failed chunk is still received
+	uploadFailures int
+	uploaded       int64
+	chunks         int
+	// failFinal fails the last chunk of a resumable upload once, though it
+	// is received, without finalizing the upload
+	failFinal bool
+	finalized bool
+	finalizes int
+	// published are more photos listed, as json
+	published []string
+	// created are the photos created
+	created []*streetviewpublish.Photo
+}
+
+// testPhoto returns a photo - odd ids are in the uk, even in ireland
+func testPhoto(id string) string {
+	n, _ := strconv.Atoi(strings.TrimPrefix(id, "photoid-"))
+	if n%2 == 1 {
+		return "{\"photoId\": { \"id\": \"" + id + "\" }, \"pose\": { \"accuracyMeters\": 0, \"altitude\": 93.180000, \"heading\": 0,	\"latLngPair\": { \"latitude\": 51.427768, \"longitude\": -0.853968 }}}"
+	}
+	return "{\"photoId\": { \"id\": \"" + id + "\" }, \"pose\": { \"accuracyMeters\": 0, \"altitude\": 0, \"heading\": 0,	\"latLngPair\": { \"latitude\": 54.000000, \"longitude\": -6.000000 }}}"
+}
+
+// testResults returns batch results for photos - ids not starting photoid-
+// aren't found
+func testResults(ids []string) string {
+	var results []string
+	for _, id := range ids {
+		if strings.HasPrefix(id, "photoid-") {
+			results = append(results, "{\"photo\": "+testPhoto(id)+"}")
+		} else {
+			results = append(results, "{\"status\": { \"code\": 5, \"message\": \"photo not found\" }}")
+		}
+	}
+	return "{\"results\": [" + strings.Join(results, ",") + "]}"
+}
+
+func newTestServer(counts *testCounts) *httptest.Server {
+	photoid := 0
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		//log.Printf("Got %v\n", req)
+		counts.Lock()
+		defer counts.Unlock()
+		if req.Method == "POST" && strings.HasPrefix(req.RequestURI, "/v1/photo:startUpload") {
+			// start upload
+			counts.startUploads++
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write([]byte("{ \"uploadUrl\": \"http://" + req.Host + "/upload/uploadreference\" }"))
+		} else if req.Method == "POST" && strings.HasPrefix(req.RequestURI, "/upload/") {
+			// upload, in one go or resumable
+			command := req.Header.Get("X-Goog-Upload-Command")
+			switch command {
+			case "start":
+				counts.finalized = false
+				rw.Header().Set("X-Goog-Upload-URL", "http://"+req.Host+"/upload/session")
+				return
+			case "query":
+				rw.Header().Set("X-Goog-Upload-Size-Received", strconv.FormatInt(counts.uploaded, 10))
+				if counts.finalized {
+					rw.Header().Set("X-Goog-Upload-Status", "final")
+				} else {
+					rw.Header().Set("X-Goog-Upload-Status", "active")
+				}
+				return
+			case "finalize":
+				counts.finalizes++
+				counts.finalized = true
+				return
+			case "upload", "upload, finalize":
+				counts.chunks++
+				if req.Header.Get("X-Goog-Upload-Offset") != strconv.FormatInt(counts.uploaded, 10) {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+			default:
+				counts.uploaded = 0
+			}
+			n, _ := io.Copy(io.Discard, req.Body)
+			counts.uploaded += n
+			if command == "upload, finalize" && counts.failFinal {
+				counts.failFinal = false
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if counts.uploadFailures > 0 {
+				counts.uploadFailures--
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			counts.finalized = command == "upload, finalize"
+		} else if req.Method == "POST" && strings.HasPrefix(req.RequestURI, "/v1/photo?") {
+			// create ( metadata )
+			counts.creates++
+			var photo streetviewpublish.Photo
+			json.NewDecoder(req.Body).Decode(&photo)
+			counts.created = append(counts.created, &photo)
+			photoid++
+			rw.Write([]byte("{\"photoId\": { \"id\": \"photoid-" + strconv.Itoa(photoid) + "\" } }"))
+		} else if strings.HasPrefix(req.RequestURI, "/v1/photo/photoid-") {
+			// get & update
+			id := strings.Split(strings.Split(req.RequestURI, "?")[0], "/")[3]
+			if req.Method == "PUT" {
+				counts.updates++
+			} else {
+				counts.gets++
+				if code, found := counts.getErrors[id]; found {
+					rw.WriteHeader(code)
+					rw.Write([]byte("{\"error\": { \"code\": " + strconv.Itoa(code) + ", \"message\": \"invalid argument\" }}"))
+					return
+				}
+				if counts.pending[id] != 0 {
+					if counts.pending[id] > 0 {
+						counts.pending[id]--
+					}
+					rw.WriteHeader(http.StatusNotFound)
+					rw.Write([]byte("{\"error\": { \"code\": 404, \"message\": \"photo is being processed\" }}"))
+					return
+				}
+				if status, found := counts.rejected[id]; found {
+					rw.Write([]byte("{\"photoId\": { \"id\": \"" + id + "\" }, \"mapsPublishStatus\": \"" + status + "\"}"))
+					return
+				}
+			}
+			rw.Write([]byte(testPhoto(id)))
+		} else if req.Method == "GET" && strings.HasPrefix(req.RequestURI, "/v1/photos:batchGet?") {
+			// batch get
+			rw.Write([]byte(testResults(req.URL.Query()["photoIds"])))
+		} else if req.Method == "POST" && strings.HasPrefix(req.RequestURI, "/v1/photos:batchUpdate?") {
+			// batch update
+			var request streetviewpublish.BatchUpdatePhotosRequest
+			json.NewDecoder(req.Body).Decode(&request)
+			var ids []string
+			for _, update := range request.UpdatePhotoRequests {
+				ids = append(ids, update.Photo.PhotoId.Id)
+				if counts.requests == nil {
+					counts.requests = map[string]*streetviewpublish.UpdatePhotoRequest{}
+				}
+				counts.requests[update.Photo.PhotoId.Id] = update
+			}
+			counts.updates += len(ids)
+			counts.batches++
+			rw.Write([]byte(testResults(ids)))
+		} else if req.Method == "GET" && strings.HasPrefix(req.RequestURI, "/v1/photos?") {
+			// list, in two pages
+			if req.FormValue("pageToken") == "" {
+				rw.Write([]byte("{\"photos\": [{\"photoId\": { \"id\": \"photoid-1\" }, \"captureTime\": \"2022-10-22T08:10:00Z\", \"viewCount\": \"12\", \"mapsPublishStatus\": \"PUBLISHED\", \"places\": [{\"placeId\": \"place-1\", \"name\": \"Old Forest\"}], \"pose\": { \"altitude\": 93.18, \"heading\": 45, \"latLngPair\": { \"latitude\": 51.427768, \"longitude\": -0.853968 }}}], \"nextPageToken\": \"page-2\"}"))
+			} else {
+				photos := append([]string{"{\"photoId\": { \"id\": \"photoid-2\" }, \"captureTime\": \"2022-10-22T08:11:00Z\", \"mapsPublishStatus\": \"UNSPECIFIED_MAPS_PUBLISH_STATUS\"}"}, counts.published...)
+				rw.Write([]byte("{\"photos\": [" + strings.Join(photos, ",") + "]}"))
+			}
+		} else {
+			log.Printf("*** FIX THIS - Unhandled %v\n", req)
+		}
+	}))
+}
+
+func newTestClient(ts *httptest.Server) *Client {
+	c, err := NewClient(context.Background(), &http.Client{}, option.WithEndpoint(ts.URL+"/streetviewpublish"), option.WithoutAuthentication())
+	if err != nil {
+		log.Fatalf("unable to create client %v", err)
+	}
+	return c
+}
+
+func TestUpload(t *testing.T) {
+	counts := testCounts{}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+
+	c := newTestClient(ts)
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	options := Options{Journal: path.Join(dir, "journal.json"), Workers: 4, Report: report.New("upload")}
+
+	err := c.Upload(context.Background(), options, []string{"../testdata/3601.jpg", "../testdata/flat.jpg", "../testdata/nolocation.jpg", "../testdata/good1.gpx"})
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if len(options.Report.Files) != 3 {
+		t.Fatalf("unexpected report files %d", len(options.Report.Files))
+	}
+	for i, outcome := range []string{report.Uploaded, report.Skipped, report.Uploaded} {
+		if options.Report.Files[i].Outcome != outcome {
+			t.Errorf("%s: unexpected outcome %s", options.Report.Files[i].File, options.Report.Files[i].Outcome)
+		}
+	}
+	if options.Report.Files[0].PublishStatus != "UNSPECIFIED_MAPS_PUBLISH_STATUS" {
+		t.Errorf("unexpected publish status %s", options.Report.Files[0].PublishStatus)
+	}
+	if options.Report.Files[2].Location.Source != "gpx" || len(options.Report.Files[2].Connections) != 1 {
+		t.Errorf("unexpected report entry %v", options.Report.Files[2])
+	}
+	if counts.creates != 2 {
+		t.Errorf("unexpected creates %d", counts.creates)
+	}
+	if counts.updates != 2 {
+		t.Errorf("unexpected updates %d", counts.updates)
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	counts := testCounts{}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+
+	c := newTestClient(ts)
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	options := Options{Journal: path.Join(dir, "journal.json"), SkipConnections: true}
+
+	// first run uploads only the first photo, but doesn't connect - the
+	// skipped photo means it isn't finished
+	//
+	err := c.Upload(context.Background(), options, []string{"../testdata/3601.jpg", "../testdata/flat1.jpg"})
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if _, err := os.Stat(options.Journal); err != nil {
+		t.Fatalf("journal not kept %v", err)
+	}
+
+	// second run should only upload the second photo
+	//
+	options.SkipConnections = false
+	err = c.Upload(context.Background(), options, []string{"../testdata/3601.jpg", "../testdata/nolocation.jpg", "../testdata/good1.gpx"})
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if counts.startUploads != 2 || counts.creates != 2 {
+		t.Errorf("unexpected uploads %d, creates %d", counts.startUploads, counts.creates)
+	}
+	if counts.updates != 2 {
+		t.Errorf("unexpected updates %d", counts.updates)
+	}
+
+	// finished, so a third run is a new upload, the journal keeping every
+	// photo id for delete
+	//
+	err = c.Upload(context.Background(), options, []string{"../testdata/3601.jpg"})
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if counts.startUploads != 3 || counts.creates != 3 {
+		t.Errorf("unexpected uploads %d, creates %d", counts.startUploads, counts.creates)
+	}
+	photoIds, err := JournalPhotoIds(options.Journal)
+	if err != nil || len(photoIds) != 3 {
+		t.Errorf("unexpected photo ids %v - %v", photoIds, err)
+	}
+}
+
+func TestUploadStrict(t *testing.T) {
+	counts := testCounts{}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+
+	c := newTestClient(ts)
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	options := Options{Journal: path.Join(dir, "journal.json"), Report: report.New("upload"), Strict: true}
+
+	// nothing is uploaded if any photo would be skipped
+	//
+	err := c.Upload(context.Background(), options, []string{"../testdata/3601.jpg", "../testdata/flat1.jpg"})
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+	if counts.startUploads != 0 {
+		t.Errorf("unexpected uploads %d", counts.startUploads)
+	}
+	if len(options.Report.Files) != 2 || options.Report.Files[0].Outcome != report.Skipped || options.Report.Files[1].Outcome != report.Skipped {
+		t.Errorf("unexpected report %v", options.Report.Files)
+	}
+}
+
+func TestAddConnectionsBatches(t *testing.T) {
+	counts := testCounts{}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	journal, _ := loadJournal(path.Join(dir, "journal.json"))
+
+	var connections []photoConnections
+	for i := 1; i <= 24; i++ {
+		connections = append(connections, photoConnections{photoId: "photoid-" + strconv.Itoa(i), targets: []string{"photoid-1"}})
+	}
+	connections = append(connections, photoConnections{photoId: "missing", targets: []string{"photoid-1"}})
+
+	failed := c.addConnections(connections, journal, false)
+	if len(failed) != 1 || failed["missing"] == nil {
+		t.Errorf("unexpected failures %v", failed)
+	}
+	if counts.updates != 24 || counts.batches != 2 {
+		t.Errorf("unexpected updates %d in %d batches", counts.updates, counts.batches)
+	}
+}
+
+func TestUploadHeadingOverride(t *testing.T) {
+	counts := testCounts{}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	tour, _ := manifest.Load("../testdata/tour.yaml")
+	options := Options{Journal: path.Join(dir, "journal.json"), Tour: tour, SkipConnections: true}
+
+	// the manifest heading is set when the photo is created, even though
+	// it isn't connected
+	//
+	err := c.Upload(context.Background(), options, tour.Files())
+	if err != nil {
+		t.Errorf("unexpected fail %v", err)
+	}
+	if len(counts.created) != 2 || counts.updates != 0 {
+		t.Fatalf("unexpected creates %d, updates %d", len(counts.created), counts.updates)
+	}
+	headings := 0
+	for _, photo := range counts.created {
+		if photo.Pose.Heading == 90 {
+			headings++
+		}
+	}
+	if headings != 1 {
+		t.Errorf("heading not set %v %v", counts.created[0].Pose, counts.created[1].Pose)
+	}
+}
+
+func TestAddConnectionsPose(t *testing.T) {
+	counts := testCounts{}
+	ts := newTestServer(&counts)
+	defer ts.Close()
+	c := newTestClient(ts)
+
+	dir, _ := os.MkdirTemp("", "journal")
+	defer os.RemoveAll(dir)
+	journal, _ := loadJournal(path.Join(dir, "journal.json"))
+
+	connections := []photoConnections{{photoId: "photoid-1", targets: []string{"photoid-2"}, heading: 123.5, pitch: 1.5, roll: -2}}
+	failed := c.addConnections(connections, journal, false)
+	if len(failed) != 0 {
+		t.Errorf("unexpected failures %v", failed)
+	}
+
+	// heading, pitch and roll are set, the rest of the pose is kept
+	//
+	request := counts.requests["photoid-1"]
+	if request == nil {
+		t.Fatalf("photo not updated")
+	}
+	pose := request.Photo.Pose
+	if pose == nil || pose.Heading != 123.5 || pose.Pitch != 1.5 || pose.Roll != -2 {
+		t.Fatalf("unexpected pose %v", pose)
+	}
+	if pose.Altitude != 93.18 || pose.LatLngPair == nil || pose.LatLngPair.Latitude != 51.427768 {
+		t.Errorf("pose location not kept %v", pose)
+	}
+}
@@ -0,0 +1,235 @@
+// token cache functions
+//
+// The OAuth token is cached as JSON in a file only the user can read, in
+// 360tools under the user cache directory.  With a passphrase the token is
+// encrypted with AES-256-GCM, using a key derived from the passphrase with
+// PBKDF2-HMAC-SHA256.  Tokens cached by older versions, gob encoded and
+// readable by anyone, are moved into the store
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/oauth2"
+)
+
+// pbkdf2Iterations is the cost of deriving the key for new encrypted tokens,
+// and maxPBKDF2Iterations the most a cached token can ask for - so a
+// tampered file can't tie up the cpu
+const (
+	pbkdf2Iterations    = 200000
+	maxPBKDF2Iterations = 10 * pbkdf2Iterations
+)
+
+// tokenStore reads and writes the cached token.  If legacy is set, a token
+// cached there by an older version is moved into the store
+type tokenStore struct {
+	file       string
+	passphrase string
+	legacy     string
+}
+
+// encryptedToken is how an encrypted token is stored
+type encryptedToken struct {
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// tokenCacheFile returns the cache file for tokens of the client and scopes
+func tokenCacheFile(config *oauth2.Config) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(config.ClientID))
+	hash.Write([]byte(config.ClientSecret))
+	hash.Write([]byte(strings.Join(config.Scopes, " ")))
+	return filepath.Join(dir, "360tools", fmt.Sprintf("token-%08x.json", hash.Sum32())), nil
+}
+
+// legacyTokenFile returns where older versions cached tokens of the client
+// and scopes
+func legacyTokenFile(config *oauth2.Config) string {
+	dir := "."
+	switch runtime.GOOS {
+	case "darwin":
+		dir = filepath.Join(os.Getenv("HOME"), "Library", "Caches")
+	case "linux", "freebsd":
+		dir = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(config.ClientID))
+	hash.Write([]byte(config.ClientSecret))
+	hash.Write([]byte(strings.Join(config.Scopes, " ")))
+	return filepath.Join(dir, url.QueryEscape(fmt.Sprintf("go-api-demo-tok%v", hash.Sum32())))
+}
+
+// load returns the cached token, or an error satisfying os.IsNotExist if
+// there isn't one
+func (s *tokenStore) load() (*oauth2.Token, error) {
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) && s.legacy != "" {
+		return s.migrate(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var encrypted encryptedToken
+	err = json.Unmarshal(data, &encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cached token %s: %v", s.file, err)
+	}
+	if encrypted.Ciphertext != nil {
+		if s.passphrase == "" {
+			return nil, fmt.Errorf("cached token %s is encrypted - give --token-passphrase", s.file)
+		}
+		if encrypted.Iterations < 1 || encrypted.Iterations > maxPBKDF2Iterations {
+			return nil, fmt.Errorf("invalid cached token %s - %d iterations", s.file, encrypted.Iterations)
+		}
+		block, err := aes.NewCipher(deriveKey(s.passphrase, encrypted.Salt, encrypted.Iterations))
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if len(encrypted.Nonce) != gcm.NonceSize() {
+			return nil, fmt.Errorf("invalid cached token %s", s.file)
+		}
+		data, err = gcm.Open(nil, encrypted.Nonce, encrypted.Ciphertext, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt cached token %s - wrong passphrase?", s.file)
+		}
+	}
+
+	token := new(oauth2.Token)
+	err = json.Unmarshal(data, token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cached token %s: %v", s.file, err)
+	}
+	return token, nil
+}
+
+// save writes the token, readable only by the user.  The file is replaced
+// in one go so a failure never leaves half a token
+func (s *tokenStore) save(token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	if s.passphrase != "" {
+		encrypted := encryptedToken{Iterations: pbkdf2Iterations, Salt: make([]byte, 16)}
+		_, err = rand.Read(encrypted.Salt)
+		if err != nil {
+			return err
+		}
+		block, err := aes.NewCipher(deriveKey(s.passphrase, encrypted.Salt, encrypted.Iterations))
+		if err != nil {
+			return err
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+		encrypted.Nonce = make([]byte, gcm.NonceSize())
+		_, err = rand.Read(encrypted.Nonce)
+		if err != nil {
+			return err
+		}
+		encrypted.Ciphertext = gcm.Seal(nil, encrypted.Nonce, data, nil)
+		data, err = json.Marshal(encrypted)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(s.file), 0700)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.file), "token.*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0600)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.file)
+}
+
+// saveOrWarn saves the token, if there is a store, only logging any error
+// as the token can still be used
+func (s *tokenStore) saveOrWarn(token *oauth2.Token) {
+	if s == nil {
+		return
+	}
+	err := s.save(token)
+	if err != nil {
+		log.Printf("Warning: failed to cache oauth token: %v", err)
+	}
+}
+
+// migrate moves a token cached by an older version into the store, then
+// removes the old file.  Returns notFound if there isn't one
+func (s *tokenStore) migrate(notFound error) (*oauth2.Token, error) {
+	f, err := os.Open(s.legacy)
+	if err != nil {
+		return nil, notFound
+	}
+	token := new(oauth2.Token)
+	err = gob.NewDecoder(f).Decode(token)
+	f.Close()
+	if err != nil {
+		log.Printf("Warning: removing unreadable old cached token %s: %v", s.legacy, err)
+		os.Remove(s.legacy)
+		return nil, notFound
+	}
+
+	// only remove the old token once it is safely stored
+	//
+	err = s.save(token)
+	if err != nil {
+		log.Printf("Warning: unable to move old cached token %s: %v", s.legacy, err)
+		return token, nil
+	}
+	err = os.Remove(s.legacy)
+	if err != nil {
+		log.Printf("Warning: unable to remove old cached token %s: %v", s.legacy, err)
+	}
+	log.Printf("Moved old cached token %s to %s", s.legacy, s.file)
+	return token, nil
+}
+
+// deriveKey returns the AES-256 key for a passphrase
+func deriveKey(passphrase string, salt []byte, iterations int) []byte {
+	return pbkdf2.Key([]byte(passphrase), salt, iterations, 32, sha256.New)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestTokenStore(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "token")
+	defer os.RemoveAll(dir)
+	token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Round(time.Second)}
+
+	// missing
+	//
+	store := &tokenStore{file: filepath.Join(dir, "360tools", "token.json")}
+	_, err := store.load()
+	if !os.IsNotExist(err) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// only readable by the user
+	//
+	err = store.save(token)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	info, _ := os.Stat(store.file)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("unexpected permissions %v", info.Mode())
+	}
+	loaded, err := store.load()
+	if err != nil || loaded.AccessToken != "access" || loaded.RefreshToken != "refresh" || !loaded.Expiry.Equal(token.Expiry) {
+		t.Errorf("unexpected token %v %v", loaded, err)
+	}
+
+	// encrypted
+	//
+	store.passphrase = "secret"
+	err = store.save(token)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	data, _ := os.ReadFile(store.file)
+	if strings.Contains(string(data), "refresh") {
+		t.Errorf("token not encrypted")
+	}
+	loaded, err = store.load()
+	if err != nil || loaded.RefreshToken != "refresh" {
+		t.Errorf("unexpected token %v %v", loaded, err)
+	}
+	for _, passphrase := range []string{"wrong", ""} {
+		_, err = (&tokenStore{file: store.file, passphrase: passphrase}).load()
+		if err == nil || os.IsNotExist(err) {
+			t.Errorf("%s: unexpected error %v", passphrase, err)
+		}
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 7914 section 11, the first 32 bytes
+	//
+	key := deriveKey("passwd", []byte("salt"), 1)
+	if hex.EncodeToString(key) != "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" {
+		t.Errorf("unexpected key %x", key)
+	}
+}
+
+func TestTokenStoreIterations(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "token")
+	defer os.RemoveAll(dir)
+	store := &tokenStore{file: filepath.Join(dir, "token.json"), passphrase: "secret"}
+	os.WriteFile(store.file, []byte(`{"iterations": 2000000000, "salt": "c2FsdA==", "nonce": "AAAAAAAAAAAAAAAA", "ciphertext": "AAAA"}`), 0600)
+
+	start := time.Now()
+	_, err := store.load()
+	if err == nil || !strings.Contains(err.Error(), "iterations") || time.Since(start) > time.Second {
+		t.Errorf("unexpected error %v after %s", err, time.Since(start))
+	}
+}
+
+func TestTokenStoreMigrate(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "token")
+	defer os.RemoveAll(dir)
+	token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}
+	legacy := filepath.Join(dir, "go-api-demo-tok123")
+	f, _ := os.OpenFile(legacy, os.O_CREATE|os.O_WRONLY, 0644)
+	gob.NewEncoder(f).Encode(token)
+	f.Close()
+
+	// moved into the encrypted store, and the old file removed
+	//
+	store := &tokenStore{file: filepath.Join(dir, "360tools", "token.json"), passphrase: "secret", legacy: legacy}
+	loaded, err := store.load()
+	if err != nil || loaded.RefreshToken != "refresh" {
+		t.Fatalf("unexpected token %v %v", loaded, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("old token not removed %v", err)
+	}
+	data, _ := os.ReadFile(store.file)
+	if strings.Contains(string(data), "refresh") {
+		t.Errorf("token not encrypted")
+	}
+	loaded, err = store.load()
+	if err != nil || loaded.RefreshToken != "refresh" {
+		t.Errorf("unexpected token %v %v", loaded, err)
+	}
+
+	// nothing to move
+	//
+	_, err = (&tokenStore{file: filepath.Join(dir, "other.json"), legacy: legacy}).load()
+	if !os.IsNotExist(err) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestOAuthConfig(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "client")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "client_secret.json")
+	os.WriteFile(file, []byte(`{"installed": {"client_id": "id.apps.googleusercontent.com", "client_secret": "secret", "auth_uri": "https://accounts.google.com/o/oauth2/auth", "token_uri": "https://oauth2.googleapis.com/token", "redirect_uris": ["http://localhost"]}}`), 0600)
+
+	empty, junk := "", "junk"
+	creds := &googleFlags{clientID: &empty, secret: &empty, clientSecret: &file}
+	config, err := oauthConfig(creds)
+	if err != nil || config.ClientID != "id.apps.googleusercontent.com" || config.ClientSecret != "secret" || len(config.Scopes) != 1 {
+		t.Errorf("unexpected config %v %v", config, err)
+	}
+
+	// a client id overrides the file
+	//
+	creds = &googleFlags{clientID: &junk, clientIDFile: &empty, secret: &junk, secretFile: &empty, clientSecret: &file}
+	config, err = oauthConfig(creds)
+	if err != nil || config.ClientID != "junk" {
+		t.Errorf("unexpected config %v %v", config, err)
+	}
+
+	os.WriteFile(file, []byte("junk"), 0600)
+	creds = &googleFlags{clientID: &empty, secret: &empty, clientSecret: &file}
+	_, err = oauthConfig(creds)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
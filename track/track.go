@@ -0,0 +1,184 @@
+// Package track reads gpx tracks, to locate photos taken without a
+// location.
+package track
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/plord12/360tools/geo"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// IsTrack returns true if the file is a gpx track, by extension
+func IsTrack(file string) bool {
+	return strings.ToLower(filepath.Ext(file)) == ".gpx"
+}
+
+// Position returns the latitude, longitude and altitude at a time along the
+// tracks in a gpx file, interpolating between the points either side.  It
+// is an error if the time isn't covered by the tracks
+func Position(timestamp time.Time, gpxFilename string) (float64, float64, float64, error) {
+
+	gpxBytes, err := os.ReadFile(gpxFilename)
+	if err != nil {
+		return 0.0, 0.0, 0.0, err
+	}
+
+	gpxFile, err := gpx.ParseBytes(gpxBytes)
+	if err != nil {
+		return 0.0, 0.0, 0.0, err
+	}
+
+	var lastPoint gpx.GPXPoint
+
+	for _, track := range gpxFile.Tracks {
+		for _, segment := range track.Segments {
+			for _, point := range segment.Points {
+				if (timestamp.Equal(lastPoint.Timestamp) || timestamp.After(lastPoint.Timestamp)) &&
+					(timestamp.Equal(point.Timestamp) || timestamp.Before(point.Timestamp)) {
+
+					// two points in the same place could result in / 0 , but can't happen
+					//
+
+					// get distance between the points
+					//
+					x, y := geo.Displacement(lastPoint.Latitude, lastPoint.Longitude, point.Latitude, point.Longitude)
+
+					// proportion distance base on time difference
+					//
+					diff := float64(timestamp.Unix()-lastPoint.Timestamp.Unix()) / float64(point.Timestamp.Unix()-lastPoint.Timestamp.Unix())
+					lat, lon := geo.Location(lastPoint.Latitude, lastPoint.Longitude, x*diff, y*diff)
+					alt := 0.0
+					if lastPoint.Elevation.NotNull() && point.Elevation.NotNull() {
+						alt = lastPoint.Elevation.Value() + (point.Elevation.Value()-lastPoint.Elevation.Value())*diff
+					}
+
+					return lat, lon, alt, nil
+				}
+				lastPoint = point
+			}
+		}
+	}
+
+	// not found
+	//
+	return 0.0, 0.0, 0.0, errors.New("Timestamp " + timestamp.String() + " not found in GPX")
+}
+
+// Merge writes the tracks from all the gpx files to a single gpx file
+func Merge(gpxFilenames []string, gpxOutputFilename string) error {
+
+	outputGpxFile := new(gpx.GPX)
+
+	for _, gpxFilename := range gpxFilenames {
+		gpxBytes, err := os.ReadFile(gpxFilename)
+		if err != nil {
+			return err
+		}
+
+		gpxFile, err := gpx.ParseBytes(gpxBytes)
+		if err != nil {
+			return err
+		}
+
+		outputGpxFile.Tracks = append(outputGpxFile.Tracks, gpxFile.Tracks...)
+	}
+
+	output, err := os.Create(gpxOutputFilename)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	xmlBytes, err := outputGpxFile.ToXml(gpx.ToXmlParams{Version: "1.1", Indent: true})
+	if err != nil {
+		// not sure how this can fail
+		return err
+	}
+	output.Write(xmlBytes)
+
+	return nil
+}
+
+// MergeTemp merges any gpx files among filenames into a temporary tracks
+// file, which the caller should remove.  Also returns true if there were any
+// gpx files
+func MergeTemp(filenames []string) (string, bool, error) {
+
+	var gpxFiles []string
+	for _, filename := range filenames {
+		if IsTrack(filename) {
+			gpxFiles = append(gpxFiles, filename)
+		}
+	}
+
+	file, err := os.CreateTemp("", "tracks.*.gpx")
+	if err != nil {
+		return "", false, err
+	}
+	file.Close()
+
+	err = Merge(gpxFiles, file.Name())
+	if err != nil {
+		os.Remove(file.Name())
+		return "", false, err
+	}
+
+	return file.Name(), len(gpxFiles) > 0, nil
+}
+
+// Along returns how far along the tracks in a gpx file, in metres, each
+// location is - the distance to the track point nearest it.  Tracks are
+// taken in the order they appear in the file
+func Along(gpxFilename string, lats []float64, lons []float64) ([]float64, error) {
+
+	gpxBytes, err := os.ReadFile(gpxFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	gpxFile, err := gpx.ParseBytes(gpxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// distance along the tracks of each point
+	//
+	var points []gpx.GPXPoint
+	var distances []float64
+	distance := 0.0
+	for _, track := range gpxFile.Tracks {
+		for _, segment := range track.Segments {
+			for _, point := range segment.Points {
+				if len(points) > 0 {
+					last := points[len(points)-1]
+					x, y := geo.Displacement(last.Latitude, last.Longitude, point.Latitude, point.Longitude)
+					distance += math.Hypot(x, y)
+				}
+				points = append(points, point)
+				distances = append(distances, distance)
+			}
+		}
+	}
+	if len(points) == 0 {
+		return nil, errors.New("no track points in GPX")
+	}
+
+	along := make([]float64, len(lats))
+	for i := range lats {
+		nearest := math.Inf(1)
+		for j, point := range points {
+			x, y := geo.Displacement(lats[i], lons[i], point.Latitude, point.Longitude)
+			if d := math.Hypot(x, y); d < nearest {
+				nearest = d
+				along[i] = distances[j]
+			}
+		}
+	}
+	return along, nil
+}
@@ -1,4 +1,4 @@
-package main
+package track
 
 import (
 	"math"
@@ -9,7 +9,7 @@ import (
 
 func TestGPXNoSuchFile(t *testing.T) {
 	time := time.Time{}
-	_, _, _, err := getMetadataFromGPX(time, "junk")
+	_, _, _, err := Position(time, "junk")
 	if err == nil {
 		t.Errorf("didn't fail")
 	}
@@ -17,7 +17,7 @@ func TestGPXNoSuchFile(t *testing.T) {
 
 func TestGPXJunkFile(t *testing.T) {
 	time := time.Time{}
-	_, _, _, err := getMetadataFromGPX(time, "testdata/junk.gpx")
+	_, _, _, err := Position(time, "../testdata/junk.gpx")
 	if err == nil {
 		t.Errorf("didn't fail")
 	}
@@ -25,7 +25,7 @@ func TestGPXJunkFile(t *testing.T) {
 
 func TestGPXNotFoundGood1(t *testing.T) {
 	time := time.Time{}
-	lat, lon, ele, err := getMetadataFromGPX(time, "testdata/good1.gpx")
+	lat, lon, ele, err := Position(time, "../testdata/good1.gpx")
 	if err != nil {
 		t.Errorf("unexpected fail %v", err)
 	}
@@ -42,7 +42,7 @@ func TestGPXNotFoundGood1(t *testing.T) {
 
 func TestGPXFoundGood1(t *testing.T) {
 	time := time.Date(2022, time.October, 22, 8, 10, 0, 0, time.UTC)
-	lat, lon, ele, err := getMetadataFromGPX(time, "testdata/good1.gpx")
+	lat, lon, ele, err := Position(time, "../testdata/good1.gpx")
 	if err != nil {
 		t.Errorf("unexpected fail %v", err)
 	}
@@ -59,28 +59,28 @@ func TestGPXFoundGood1(t *testing.T) {
 
 func TestGPXOverrunGood1(t *testing.T) {
 	time := time.Date(2023, time.October, 22, 8, 40, 0, 0, time.UTC)
-	_, _, _, err := getMetadataFromGPX(time, "testdata/good1.gpx")
+	_, _, _, err := Position(time, "../testdata/good1.gpx")
 	if err == nil {
 		t.Errorf("didn't fail")
 	}
 }
 
 func TestGPXJunkOutputMerge1(t *testing.T) {
-	err := mergeGPX([]string{"testdata/good1.gpx"}, "")
+	err := Merge([]string{"../testdata/good1.gpx"}, "")
 	if err == nil {
 		t.Errorf("didn't fail")
 	}
 }
 
 func TestGPXJunkInputtMerge1(t *testing.T) {
-	err := mergeGPX([]string{"junk.gpx"}, "")
+	err := Merge([]string{"junk.gpx"}, "")
 	if err == nil {
 		t.Errorf("didn't fail")
 	}
 }
 
 func TestGPXJunkInputtMerge2(t *testing.T) {
-	err := mergeGPX([]string{"testdata/junk.gpx"}, "")
+	err := Merge([]string{"../testdata/junk.gpx"}, "")
 	if err == nil {
 		t.Errorf("didn't fail")
 	}
@@ -89,13 +89,34 @@ func TestGPXJunkInputtMerge2(t *testing.T) {
 func TestGPXGoodMerge1(t *testing.T) {
 	f, _ := os.CreateTemp("", "out.gpx")
 	defer os.Remove(f.Name())
-	err := mergeGPX([]string{"testdata/good1.gpx"}, f.Name())
+	err := Merge([]string{"../testdata/good1.gpx"}, f.Name())
 	if err != nil {
 		t.Errorf("failed %v", err)
 	}
 	time := time.Date(2022, time.October, 22, 8, 10, 0, 0, time.UTC)
-	_, _, _, err = getMetadataFromGPX(time, f.Name())
+	_, _, _, err = Position(time, f.Name())
 	if err != nil {
 		t.Errorf("couldnt read mergerd file %v", err)
 	}
 }
+
+func TestIsTrack(t *testing.T) {
+	if !IsTrack("a.gpx") || !IsTrack("a.GPX") || IsTrack("a.jpg") {
+		t.Errorf("unexpected track")
+	}
+}
+
+func TestAlong(t *testing.T) {
+	along, err := Along("../testdata/good1.gpx", []float64{54, 50.01, 51}, []float64{-6, -2, -3})
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+	if along[1] != 0 || along[2] <= along[1] || along[0] <= along[2] {
+		t.Errorf("unexpected distances %v", along)
+	}
+
+	_, err = Along("../testdata/junk.gpx", []float64{54}, []float64{-6})
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
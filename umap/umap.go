@@ -1,26 +1,34 @@
-// umap functions
+// Package umap generates OpenStreetMap uMap files for photos hosted on a web
+// server.
 //
 // FIX THIS - add support for tracks
 //
 //	Combine all *.gpx > tracks.gx ( multiple <trk> tags )
 
-package main
+package umap
 
 import (
 	_ "embed"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path"
-	"path/filepath"
 	"text/template"
+
+	"github.com/plord12/360tools/manifest"
+	"github.com/plord12/360tools/metadata"
+	"github.com/plord12/360tools/report"
+	"github.com/plord12/360tools/track"
 )
 
+// PhotoData is the data for the 360 photo html template
 type PhotoData struct {
 	Photo string
 }
 
+// UmapData is the data for the umap template
 type UmapData struct {
 	Name         string
 	WebURL       string
@@ -41,28 +49,32 @@ var photo360htmlTemplate string
 //go:embed umap.template
 var umapTemplate string
 
-func createUmapFiles(outputDirectory *string, webURL *string, filenames []string) error {
+// Create generates the uMap files for the photos and gpx tracks in a new
+// output directory, adding the outcome for each photo to the report.  The
+// photos are expected to be copied to webURL.  Photos that can't be used
+// are skipped, unless strict when the first one is an error
+func Create(outputDirectory string, webURL string, filenames []string, runReport *report.Report, tour *manifest.Manifest, strict bool) error {
 
-	_, err := os.Stat(*outputDirectory)
+	_, err := os.Stat(outputDirectory)
 	if !os.IsNotExist(err) {
-		return fmt.Errorf("output directory %s already exists, refusing to overwrite", *outputDirectory)
+		return fmt.Errorf("output directory %s already exists, refusing to overwrite", outputDirectory)
 	}
 
-	err = os.Mkdir(*outputDirectory, 0755)
+	err = os.Mkdir(outputDirectory, 0755)
 	if err != nil {
 		return fmt.Errorf("unable to create output directory - %v", err)
 	}
 
 	// csv files for 360 and non-360 images
 	//
-	csvPlain, err := os.Create(path.Join(*outputDirectory, "photos.csv"))
+	csvPlain, err := os.Create(path.Join(outputDirectory, "photos.csv"))
 	if err != nil {
 		return fmt.Errorf("unable to create output file - %v", err)
 	}
 	defer csvPlain.Close()
 	csvPlain.WriteString("photo,lat,lon\n")
 
-	csv360, err := os.Create(path.Join(*outputDirectory, "photos360.csv"))
+	csv360, err := os.Create(path.Join(outputDirectory, "photos360.csv"))
 	if err != nil {
 		return fmt.Errorf("unable to create output file - %v", err)
 	}
@@ -87,12 +99,12 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 	// process gpx files first
 	//
 	for _, imageFilename := range filenames {
-		if filepath.Ext(imageFilename) == ".gpx" {
+		if track.IsTrack(imageFilename) {
 			gpxFiles = append(gpxFiles, imageFilename)
 			hasTracks = true
 		}
 	}
-	err = mergeGPX(gpxFiles, path.Join(*outputDirectory, "tracks.gpx"))
+	err = track.Merge(gpxFiles, path.Join(outputDirectory, "tracks.gpx"))
 	if err != nil {
 		return fmt.Errorf("unable to create tracks.gpx file - %v", err)
 	}
@@ -100,21 +112,21 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 	// process jpgs
 	for _, imageFilename := range filenames {
 
-		if filepath.Ext(imageFilename) == ".jpg" || filepath.Ext(imageFilename) == ".JPG" {
+		if metadata.IsPhoto(imageFilename) {
 
-			timestamp, lat, long, altitude, err := getMetadata(imageFilename)
-			if err != nil || lat != lat || long != long {
-				if hasTracks {
-					lat, long, _, err = getMetadataFromGPX(timestamp, path.Join(*outputDirectory, "tracks.gpx"))
-					if err != nil {
-						log.Printf("%s: Unable to get metadata from gpx: %v, skipping picture\n", imageFilename, err)
-						continue
-					}
-				} else {
-					log.Printf("%s: Unable to get metadata: %v, skipping picture\n", imageFilename, err)
-					continue
+			entry := &report.Entry{File: imageFilename, Outcome: report.Generated}
+			runReport.Add(entry)
+
+			timestamp, lat, long, altitude, source, err := tour.Locate(imageFilename, path.Join(outputDirectory, "tracks.gpx"), hasTracks)
+			if err != nil {
+				log.Printf("%s: %v, skipping picture\n", imageFilename, err)
+				entry.SetError(report.Skipped, err)
+				if strict {
+					return fmt.Errorf("%s: %v", imageFilename, err)
 				}
+				continue
 			}
+			entry.SetLocation(timestamp, lat, long, altitude, source)
 
 			log.Printf("%s: Timestamp %s\n", imageFilename, timestamp)
 			log.Printf("%s: Latitude %f, Longitude %f\n", imageFilename, lat, long)
@@ -137,7 +149,7 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 			totalLong = totalLong + long
 			totalCount = totalCount + 1
 
-			if is360(imageFilename) {
+			if metadata.Is360(imageFilename) {
 
 				has360Photos = true
 
@@ -152,10 +164,14 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 				if err != nil {
 					if err != nil {
 						log.Printf("%s: Unable to get photo360-html.template: %v, skipping picture\n", imageFilename, err)
+						entry.SetError(report.Failed, err)
+						if strict {
+							return fmt.Errorf("%s: %v", imageFilename, err)
+						}
 						continue
 					}
 				}
-				html, err := os.Create(path.Join(*outputDirectory, path.Base(imageFilename)+".html"))
+				html, err := os.Create(path.Join(outputDirectory, path.Base(imageFilename)+".html"))
 				if err != nil {
 					return fmt.Errorf("unable to create output file - %v", err)
 				}
@@ -164,6 +180,10 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 				if err != nil {
 					if err != nil {
 						log.Printf("%s: Unable to process photo360-html.template: %v, skipping picture\n", imageFilename, err)
+						entry.SetError(report.Failed, err)
+						if strict {
+							return fmt.Errorf("%s: %v", imageFilename, err)
+						}
 						continue
 					}
 				}
@@ -178,10 +198,14 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 
 				// create thumbnail
 				//
-				thumb := path.Join(*outputDirectory, path.Base(imageFilename)+"-thumb.jpg")
+				thumb := path.Join(outputDirectory, path.Base(imageFilename)+"-thumb.jpg")
 				err := exec.Command("convert", imageFilename, "-resize", "450", thumb).Run()
 				if err != nil {
 					log.Printf("%s: Unable to create thumbnail: %v, skipping picture\n", imageFilename, err)
+					entry.SetError(report.Failed, err)
+					if strict {
+						return fmt.Errorf("%s: %v", imageFilename, err)
+					}
 					continue
 				}
 			}
@@ -191,12 +215,20 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 			r, err := os.Open(imageFilename)
 			if err != nil {
 				log.Printf("%s: Unable to copy photo: %v\n", imageFilename, err)
+				entry.SetError(report.Failed, err)
+				if strict {
+					return fmt.Errorf("%s: %v", imageFilename, err)
+				}
 				continue
 			}
 			defer r.Close()
-			w, err := os.Create(path.Join(*outputDirectory, path.Base(imageFilename)))
+			w, err := os.Create(path.Join(outputDirectory, path.Base(imageFilename)))
 			if err != nil {
 				log.Printf("%s: Unable to copy photo: %v\n", imageFilename, err)
+				entry.SetError(report.Failed, err)
+				if strict {
+					return fmt.Errorf("%s: %v", imageFilename, err)
+				}
 				continue
 			}
 			defer w.Close()
@@ -205,10 +237,15 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 		}
 	}
 
-	// umap file
+	// umap file, named after the tour or the last part of the web url
 	//
-	td := UmapData{Name: path.Base(*webURL),
-		WebURL:       *webURL,
+	name := path.Base(webURL)
+	if tour != nil && tour.Name != "" {
+		name = tour.Name
+	}
+	quoted, _ := json.Marshal(name)
+	td := UmapData{Name: string(quoted[1 : len(quoted)-1]),
+		WebURL:       webURL,
 		East:         east,
 		West:         west,
 		North:        north,
@@ -224,7 +261,7 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 			return fmt.Errorf("unable to get umap.template: %v", err)
 		}
 	}
-	umap, err := os.Create(path.Join(*outputDirectory, "photos.umap"))
+	umap, err := os.Create(path.Join(outputDirectory, "photos.umap"))
 	if err != nil {
 		return fmt.Errorf("unable to create output file - %v", err)
 	}
@@ -236,9 +273,9 @@ func createUmapFiles(outputDirectory *string, webURL *string, filenames []string
 		}
 	}
 
-	log.Printf("uMap files have been generated in %s directory\n", *outputDirectory)
+	log.Printf("uMap files have been generated in %s directory\n", outputDirectory)
 	log.Printf("To use in uMap :\n")
-	log.Printf("1. Copy photos, html pages and csv files to %s\n", *webURL)
+	log.Printf("1. Copy photos, html pages and csv files to %s\n", webURL)
 	log.Printf("2. On uMap server, click \"Create a map\"\n")
 	log.Printf("3. Click \"Edit map settings\" ( cog wheel ), \"Advanced actions\" then \"Empty\"\n")
 	log.Printf("4. Click \"Import data\" ( up arrow ), browse and upload photos.umap then \"Import\"\n")
@@ -1,4 +1,4 @@
-package main
+package umap
 
 import (
 	"bufio"
@@ -7,14 +7,17 @@ import (
 	"log"
 	"os"
 	"path"
+	"strings"
 	"testing"
+
+	"github.com/plord12/360tools/manifest"
 )
 
 func TestUmapDirectoryExists(t *testing.T) {
 
 	dir := "."
 	server := "http://server"
-	err := createUmapFiles(&dir, &server, []string{"testdata/good1.gpx"})
+	err := Create(dir, server, []string{"../testdata/good1.gpx"}, nil, nil, false)
 	if err == nil {
 		t.Errorf("didn't fail")
 	}
@@ -25,7 +28,7 @@ func TestUmap1(t *testing.T) {
 	os.RemoveAll(dir)
 	defer os.RemoveAll(dir)
 	server := "http://server"
-	err := createUmapFiles(&dir, &server, []string{"testdata/flat1.jpg", "testdata/3601.jpg", "testdata/nolocation.jpg", "testdata/good1.gpx"})
+	err := Create(dir, server, []string{"../testdata/flat1.jpg", "../testdata/3601.jpg", "../testdata/nolocation.jpg", "../testdata/good1.gpx"}, nil, nil, false)
 	if err != nil {
 		t.Errorf("unexpected fail %v", err)
 	}
@@ -78,3 +81,30 @@ func lineCount(fileName string) int {
 	}
 	return lineCount
 }
+
+func TestUmapStrict(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "umap")
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+	err := Create(dir, "http://server", []string{"../testdata/3601.jpg", "../testdata/nolocation.jpg"}, nil, nil, true)
+	if err == nil {
+		t.Errorf("didn't fail")
+	}
+}
+
+func TestUmapTourName(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "umap")
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+	tour, _ := manifest.Load("../testdata/tour.yaml")
+	tour.Name = `Old "Forest"`
+	err := Create(dir, "http://server/tour", []string{"../testdata/3601.jpg"}, nil, tour, false)
+	if err != nil {
+		t.Fatalf("unexpected fail %v", err)
+	}
+
+	data, _ := os.ReadFile(path.Join(dir, "photos.umap"))
+	if !strings.Contains(string(data), `"name": "Old \"Forest\"",`) {
+		t.Errorf("name not set")
+	}
+}